@@ -0,0 +1,175 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+
+	"ledger-lens/backend/models"
+	"ledger-lens/backend/utils"
+
+	"gorm.io/gorm/clause"
+)
+
+// ecbBaseCurrency is the base of ECB reference rates and the default for CSV files without a base column
+const ecbBaseCurrency = "EUR"
+
+// LoadExchangeRatesFromEnv loads EXCHANGE_RATES_FILE, if set. A bad file is
+// logged rather than fatal so the server still starts without rates.
+func LoadExchangeRatesFromEnv() {
+	path := os.Getenv("EXCHANGE_RATES_FILE")
+	if path == "" {
+		return
+	}
+	n, err := LoadExchangeRates(path)
+	if err != nil {
+		log.Printf("Failed to load exchange rates from %s: %v", path, err)
+		return
+	}
+	log.Printf("Loaded %d exchange rates from %s", n, path)
+}
+
+// LoadExchangeRates upserts the rates in a CSV or ECB XML file and returns how many were read
+func LoadExchangeRates(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	rates, err := ParseExchangeRates(f)
+	if err != nil {
+		return 0, err
+	}
+	if len(rates) == 0 {
+		return 0, nil
+	}
+
+	// A batch may not upsert the same key twice; the last quote in the file wins
+	index := make(map[[3]string]int, len(rates))
+	unique := rates[:0]
+	for _, r := range rates {
+		key := [3]string{r.Base, r.Currency, r.Date}
+		if i, ok := index[key]; ok {
+			unique[i] = r
+			continue
+		}
+		index[key] = len(unique)
+		unique = append(unique, r)
+	}
+	rates = unique
+
+	err = DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "base"}, {Name: "currency"}, {Name: "date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "updated_at"}),
+	}).CreateInBatches(&rates, 1000).Error
+	return len(rates), err
+}
+
+// ParseExchangeRates reads either an ECB eurofxref XML document or a CSV with
+// a header of date,currency,rate and an optional base column (EUR when absent)
+func ParseExchangeRates(r io.Reader) ([]models.ExchangeRate, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(512)
+	if bytes.HasPrefix(bytes.TrimSpace(bytes.TrimPrefix(head, []byte("\ufeff"))), []byte("<")) {
+		return parseECBRates(br)
+	}
+	return parseCSVRates(br)
+}
+
+type ecbEnvelope struct {
+	Days []struct {
+		Time  string `xml:"time,attr"`
+		Rates []struct {
+			Currency string `xml:"currency,attr"`
+			Rate     string `xml:"rate,attr"`
+		} `xml:"Cube"`
+	} `xml:"Cube>Cube"`
+}
+
+func parseECBRates(r io.Reader) ([]models.ExchangeRate, error) {
+	var envelope ecbEnvelope
+	if err := xml.NewDecoder(r).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("parse ECB XML: %w", err)
+	}
+
+	var rates []models.ExchangeRate
+	for _, day := range envelope.Days {
+		for _, q := range day.Rates {
+			rate, err := newExchangeRate(day.Time, ecbBaseCurrency, q.Currency, q.Rate)
+			if err != nil {
+				return nil, err
+			}
+			rates = append(rates, rate)
+		}
+	}
+	return rates, nil
+}
+
+func parseCSVRates(r io.Reader) ([]models.ExchangeRate, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read rates header: %w", err)
+	}
+	col := map[string]int{}
+	for i, h := range header {
+		col[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))] = i
+	}
+	for _, required := range []string{"date", "currency", "rate"} {
+		if _, ok := col[required]; !ok {
+			return nil, fmt.Errorf("rates file is missing a %q column", required)
+		}
+	}
+
+	var rates []models.ExchangeRate
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		base := ecbBaseCurrency
+		if i, ok := col["base"]; ok && record[i] != "" {
+			base = record[i]
+		}
+		rate, err := newExchangeRate(record[col["date"]], base, record[col["currency"]], record[col["rate"]])
+		if err != nil {
+			line, _ := reader.FieldPos(0)
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rates = append(rates, rate)
+	}
+	return rates, nil
+}
+
+func newExchangeRate(date, base, currency, rate string) (models.ExchangeRate, error) {
+	d, err := utils.NormalizeDate(date)
+	if err != nil {
+		return models.ExchangeRate{}, err
+	}
+	if strings.TrimSpace(currency) == "" || strings.TrimSpace(base) == "" {
+		return models.ExchangeRate{}, fmt.Errorf("missing currency on %s", date)
+	}
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(rate))
+	if !ok || r.Sign() <= 0 {
+		return models.ExchangeRate{}, fmt.Errorf("invalid rate %q for %s", rate, currency)
+	}
+	return models.ExchangeRate{
+		Date:     d.Format(utils.StoredDateLayout),
+		Base:     models.NormalizeCurrency(base),
+		Currency: models.NormalizeCurrency(currency),
+		Rate:     r.FloatString(10),
+	}, nil
+}
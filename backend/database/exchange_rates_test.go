@@ -0,0 +1,44 @@
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseExchangeRatesECB(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<gesmes:subject>Reference rates</gesmes:subject>
+	<Cube>
+		<Cube time="2024-01-02">
+			<Cube currency="USD" rate="1.0956"/>
+			<Cube currency="JPY" rate="155.52"/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>`
+
+	rates, err := ParseExchangeRates(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rates) != 2 {
+		t.Fatalf("Expected 2 rates, got %d", len(rates))
+	}
+	if r := rates[0]; r.Date != "20240102" || r.Base != "EUR" || r.Currency != "USD" || r.Rate != "1.0956000000" {
+		t.Errorf("Unexpected rate: %+v", r)
+	}
+}
+
+func TestParseExchangeRatesCSV(t *testing.T) {
+	rates, err := ParseExchangeRates(strings.NewReader("date,base,currency,rate\n2024/01/02,USD,TWD,30.705\n2024-01-03,,usd,1.09\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rates) != 2 || rates[0].Base != "USD" || rates[0].Currency != "TWD" || rates[1].Base != "EUR" || rates[1].Currency != "USD" {
+		t.Errorf("Unexpected rates: %+v", rates)
+	}
+
+	if _, err := ParseExchangeRates(strings.NewReader("date,currency,rate\n2024-01-02,USD,-1\n")); err == nil {
+		t.Error("Expected error for negative rate")
+	}
+}
@@ -0,0 +1,159 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"ledger-lens/backend/models"
+	"ledger-lens/backend/storage"
+	"ledger-lens/backend/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Migrate runs the data migrations SQL cannot express. The schema itself
+// comes from db/migrations and is applied with golang-migrate before start.
+func Migrate() {
+	if err := importLegacyTransactionFiles(); err != nil {
+		log.Fatal("Failed to import legacy transaction files:", err)
+	}
+
+	if err := normalizeStoredDates(); err != nil {
+		log.Fatal("Failed to normalize transaction dates:", err)
+	}
+
+	if err := backfillFingerprints(); err != nil {
+		log.Fatal("Failed to backfill transaction fingerprints:", err)
+	}
+
+	log.Println("Database migration completed")
+}
+
+// importLegacyTransactionFiles copies each user's transactions.json into the
+// transactions table once and sets migrated_at, so a ledger the user later
+// empties is not filled again on the next start. Files that cannot be read
+// are logged and left for the next start instead of stopping the server.
+func importLegacyTransactionFiles() error {
+	var files []models.UserTransaction
+	if err := DB.Where("file_path <> '' AND migrated_at IS NULL").Find(&files).Error; err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		records, err := storage.ReadTransactionFile(file.FilePath)
+		if err != nil {
+			log.Printf("Skipping legacy transactions for user %s: read %s: %v", file.UserID, file.FilePath, err)
+			continue
+		}
+
+		transactions := make([]models.Transaction, 0, len(records))
+		for _, record := range records {
+			t := legacyTransaction(file.UserID, record)
+			t.Fingerprint = t.ComputeFingerprint()
+			transactions = append(transactions, t)
+		}
+
+		imported := 0
+		err = DB.Transaction(func(tx *gorm.DB) error {
+			// Rows already present were copied before migrated_at existed
+			var count int64
+			if err := tx.Model(&models.Transaction{}).Where("user_id = ?", file.UserID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 && len(transactions) > 0 {
+				if err := tx.CreateInBatches(&transactions, 500).Error; err != nil {
+					return err
+				}
+				imported = len(transactions)
+			}
+			return tx.Model(&file).UpdateColumn("migrated_at", time.Now()).Error
+		})
+		if err != nil {
+			return fmt.Errorf("import %s: %w", file.FilePath, err)
+		}
+
+		if imported > 0 {
+			log.Printf("Imported %d legacy transactions for user %s", imported, file.UserID)
+		}
+	}
+
+	return nil
+}
+
+// legacyTransaction converts a JSON record, normalizing its date the way
+// imports do and keeping the original text in DateRaw
+func legacyTransaction(userID uuid.UUID, record map[string]interface{}) models.Transaction {
+	str := func(key string) string {
+		if v, ok := record[key].(string); ok {
+			return v
+		}
+		return ""
+	}
+
+	amount, _ := record["amount"].(float64)
+	date := str("date")
+	if d, err := utils.NormalizeDate(date); err == nil {
+		date = d.Format(utils.StoredDateLayout)
+	}
+
+	return models.Transaction{
+		UserID:       userID,
+		Date:         date,
+		DateRaw:      str("date"),
+		Category:     str("category"),
+		MainCategory: str("mainCategory"),
+		Amount:       models.MoneyFromFloat(amount, str("currency")),
+		Currency:     models.NormalizeCurrency(str("currency")),
+		Member:       str("member"),
+		Account:      str("account"),
+		Tags:         str("tags"),
+		Note:         str("note"),
+		Type:         str("type"),
+		LastUpdated:  str("lastUpdated"),
+		SourceUUID:   str("uuid"),
+	}
+}
+
+// backfillFingerprints fills in fingerprints for rows created before they existed
+func backfillFingerprints() error {
+	var transactions []models.Transaction
+	return DB.Where("fingerprint = ''").
+		FindInBatches(&transactions, 500, func(tx *gorm.DB, batch int) error {
+			for _, t := range transactions {
+				if err := tx.Model(&t).UpdateColumn("fingerprint", t.ComputeFingerprint()).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
+
+// normalizeStoredDates rewrites dates stored before normalization into
+// utils.StoredDateLayout, keeping the original text in date_raw. Rows that
+// cannot be normalized are left as they are and logged.
+func normalizeStoredDates() error {
+	var transactions []models.Transaction
+	return DB.Where("date !~ '^[0-9]{8}$' AND date_raw = ''").
+		FindInBatches(&transactions, 500, func(tx *gorm.DB, batch int) error {
+			for _, t := range transactions {
+				date, err := utils.NormalizeDate(t.Date)
+				if err != nil {
+					log.Printf("Skipping date normalization for transaction %s: %v", t.ID, err)
+					continue
+				}
+				t.DateRaw = t.Date
+				t.Date = date.Format(utils.StoredDateLayout)
+				err = tx.Model(&t).UpdateColumns(map[string]interface{}{
+					"date":        t.Date,
+					"date_raw":    t.DateRaw,
+					"fingerprint": t.ComputeFingerprint(),
+				}).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
@@ -78,7 +78,8 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	// Generate JWT
+	// Generate JWT. exp is an instant in Unix seconds, so it does not
+	// depend on the user's time zone.
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"user_id": user.ID,
 		"exp":     time.Now().Add(time.Hour * 24).Unix(),
@@ -0,0 +1,446 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"ledger-lens/backend/database"
+	"ledger-lens/backend/models"
+	"ledger-lens/backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxRolloverPeriods bounds how many past periods feed a rollover balance
+const maxRolloverPeriods = 36
+
+// BudgetInput creates or replaces a budget. Amount is read in Currency,
+// which defaults to the user's base currency.
+type BudgetInput struct {
+	Scope    string          `json:"scope"`
+	Category string          `json:"category" binding:"required"`
+	Period   string          `json:"period"`
+	Amount   json.RawMessage `json:"amount" binding:"required"`
+	Currency string          `json:"currency"`
+	Rollover string          `json:"rollover"`
+}
+
+// apply validates the input and copies it onto b
+func (in *BudgetInput) apply(b *models.Budget, baseCurrency string) error {
+	oneOf := func(name, value, fallback string, allowed ...string) (string, error) {
+		if value == "" {
+			return fallback, nil
+		}
+		for _, a := range allowed {
+			if value == a {
+				return value, nil
+			}
+		}
+		return "", fmt.Errorf("%s must be one of %s", name, strings.Join(allowed, ", "))
+	}
+
+	var err error
+	if b.Scope, err = oneOf("scope", in.Scope, models.BudgetScopeCategory,
+		models.BudgetScopeCategory, models.BudgetScopeMainCategory); err != nil {
+		return err
+	}
+	if b.Period, err = oneOf("period", in.Period, models.BudgetPeriodMonth,
+		models.BudgetPeriodWeek, models.BudgetPeriodMonth, models.BudgetPeriodYear); err != nil {
+		return err
+	}
+	if b.Rollover, err = oneOf("rollover", in.Rollover, models.RolloverNone,
+		models.RolloverNone, models.RolloverUnspent, models.RolloverAll); err != nil {
+		return err
+	}
+	if b.Category = strings.TrimSpace(in.Category); b.Category == "" {
+		return errors.New("category must not be empty")
+	}
+
+	b.Currency = baseCurrency
+	if in.Currency != "" {
+		b.Currency = models.NormalizeCurrency(in.Currency)
+		if !currencyCodePattern.MatchString(b.Currency) {
+			return fmt.Errorf("currency %q is not an ISO 4217 code", in.Currency)
+		}
+	}
+	m, err := models.ParseMoney(strings.Trim(string(in.Amount), `"`), b.Currency, models.DecimalPoint)
+	if err != nil {
+		return fmt.Errorf("amount: %w", err)
+	}
+	if m <= 0 {
+		return errors.New("amount must be positive")
+	}
+	b.Amount = m
+	return nil
+}
+
+// budgetMatches reports whether t counts against b
+func budgetMatches(b models.Budget, t models.Transaction) bool {
+	if t.Type != "支" {
+		return false
+	}
+	if b.Scope == models.BudgetScopeMainCategory {
+		return t.MainCategory == b.Category
+	}
+	return t.Category == b.Category
+}
+
+// nextPeriod returns the period following p
+func nextPeriod(p period, groupBy string, monthStartDay int) period {
+	end, _ := time.Parse(utils.StoredDateLayout, p.To)
+	return periodOf(end.AddDate(0, 0, 1), groupBy, monthStartDay)
+}
+
+// budgetPeriods returns the periods from the first one that feeds b's
+// rollover balance up to and including the one containing today
+func budgetPeriods(b models.Budget, today time.Time, monthStartDay int) []period {
+	current := periodOf(today, b.Period, monthStartDay)
+	if b.Rollover == models.RolloverNone || b.CreatedAt.IsZero() {
+		return []period{current}
+	}
+	var periods []period
+	for p := periodOf(b.CreatedAt.In(today.Location()), b.Period, monthStartDay); p.From < current.From; p = nextPeriod(p, b.Period, monthStartDay) {
+		periods = append(periods, p)
+	}
+	if len(periods) > maxRolloverPeriods {
+		periods = periods[len(periods)-maxRolloverPeriods:]
+	}
+	return append(periods, current)
+}
+
+// BudgetStatus is a budget's standing in its current period. Amounts are in
+// the base currency.
+type BudgetStatus struct {
+	Budget    models.Budget
+	From, To  string
+	Limit     models.Money
+	Carried   models.Money // rolled over from earlier periods, negative if overspent
+	Spent     models.Money
+	Projected models.Money // end-of-period spend at the current pace
+	Converted bool         // false if the limit has no exchange rate into the base currency
+}
+
+func (s BudgetStatus) Available() models.Money {
+	return s.Limit + s.Carried
+}
+
+func (s BudgetStatus) Remaining() models.Money {
+	return s.Available() - s.Spent
+}
+
+// Percent is the share of the available amount spent, rounded to one
+// decimal, or nil when nothing is available
+func (s BudgetStatus) Percent() *float64 {
+	if s.Available() <= 0 {
+		return nil
+	}
+	p := math.Round(float64(s.Spent)/float64(s.Available())*1000) / 10
+	return &p
+}
+
+func (s BudgetStatus) OverBudget() bool {
+	return s.Spent > s.Available()
+}
+
+func (s BudgetStatus) JSON(currency string) gin.H {
+	out := gin.H{
+		"budget": s.Budget,
+		"from":   s.From,
+		"to":     s.To,
+		"spent":  money(s.Spent, currency),
+	}
+	if !s.Converted {
+		out["error"] = "no exchange rate from " + s.Budget.Currency + " to " + currency
+		return out
+	}
+	out["limit"] = money(s.Limit, currency)
+	out["carried"] = money(s.Carried, currency)
+	out["available"] = money(s.Available(), currency)
+	out["remaining"] = money(s.Remaining(), currency)
+	out["projected"] = money(s.Projected, currency)
+	out["percent"] = s.Percent()
+	out["over_budget"] = s.OverBudget()
+	out["projected_over"] = s.Projected > s.Available()
+	return out
+}
+
+// evaluateBudget works out b's status on today from the user's expense rows.
+// Currencies without a rate are recorded in missing.
+func evaluateBudget(b models.Budget, rows []models.Transaction, converter *currencyConverter, today time.Time, monthStartDay int, missing map[string]bool) BudgetStatus {
+	periods := budgetPeriods(b, today, monthStartDay)
+	current := periods[len(periods)-1]
+	status := BudgetStatus{Budget: b, From: current.From, To: current.To}
+
+	spent := map[string]models.Money{}
+	for _, t := range rows {
+		if !budgetMatches(b, t) || t.Date < periods[0].From || t.Date > current.To {
+			continue
+		}
+		amount, ok := converter.Convert(t.Amount, t.Currency, t.Date)
+		if !ok {
+			missing[models.NormalizeCurrency(t.Currency)] = true
+			continue
+		}
+		d, err := time.Parse(utils.StoredDateLayout, t.Date)
+		if err != nil {
+			continue
+		}
+		spent[periodOf(d, b.Period, monthStartDay).Key] += amount
+	}
+	status.Spent = spent[current.Key]
+
+	status.Limit, status.Converted = converter.Convert(b.Amount, b.Currency, current.From)
+	if !status.Converted {
+		missing[b.Currency] = true
+		return status
+	}
+
+	for _, p := range periods[:len(periods)-1] {
+		status.Carried += status.Limit - spent[p.Key]
+		if b.Rollover == models.RolloverUnspent && status.Carried < 0 {
+			status.Carried = 0
+		}
+	}
+
+	start, _ := time.Parse(utils.StoredDateLayout, current.From)
+	end, _ := time.Parse(utils.StoredDateLayout, current.To)
+	day := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	elapsed := int64(day.Sub(start).Hours()/24) + 1
+	total := int64(end.Sub(start).Hours()/24) + 1
+	status.Projected = models.Money((int64(status.Spent)*total + elapsed/2) / elapsed)
+	return status
+}
+
+// loadBudgetStatuses evaluates all of the user's budgets as of today on
+// their clock. It also returns the currencies that could not be converted.
+func loadBudgetStatuses(settings models.UserSettings) ([]BudgetStatus, []string, error) {
+	var budgets []models.Budget
+	if err := database.DB.Where("user_id = ?", settings.UserID).Order("period, scope, category").Find(&budgets).Error; err != nil {
+		return nil, nil, err
+	}
+	if len(budgets) == 0 {
+		return []BudgetStatus{}, []string{}, nil
+	}
+
+	today := settings.Now()
+	from, to := "", ""
+	var categories, mainCategories []string
+	currencies := map[string]bool{}
+	for _, b := range budgets {
+		periods := budgetPeriods(b, today, settings.MonthStartDay)
+		if from == "" || periods[0].From < from {
+			from = periods[0].From
+		}
+		if last := periods[len(periods)-1]; last.To > to {
+			to = last.To
+		}
+		if b.Scope == models.BudgetScopeMainCategory {
+			mainCategories = append(mainCategories, b.Category)
+		} else {
+			categories = append(categories, b.Category)
+		}
+		currencies[b.Currency] = true
+	}
+
+	query := database.DB.Scopes(ledgerScope(settings.UserID)).
+		Select("date", "category", "main_category", "type", "currency", "amount_minor").
+		Where("type = ? AND date BETWEEN ? AND ?", "支", from, to)
+	switch {
+	case len(categories) > 0 && len(mainCategories) > 0:
+		query = query.Where("(category IN ? OR main_category IN ?)", categories, mainCategories)
+	case len(categories) > 0:
+		query = query.Where("category IN ?", categories)
+	default:
+		query = query.Where("main_category IN ?", mainCategories)
+	}
+	var rows []models.Transaction
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, t := range rows {
+		currencies[models.NormalizeCurrency(t.Currency)] = true
+	}
+	list := make([]string, 0, len(currencies))
+	for cur := range currencies {
+		list = append(list, cur)
+	}
+	converter, err := newCurrencyConverter(database.DB, settings.BaseCurrency, list)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	missing := map[string]bool{}
+	statuses := make([]BudgetStatus, 0, len(budgets))
+	for _, b := range budgets {
+		statuses = append(statuses, evaluateBudget(b, rows, converter, today, settings.MonthStartDay, missing))
+	}
+	rates := make([]string, 0, len(missing))
+	for cur := range missing {
+		rates = append(rates, cur)
+	}
+	sort.Strings(rates)
+	return statuses, rates, nil
+}
+
+// GetBudgets lists the user's budgets
+func GetBudgets(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	budgets := []models.Budget{}
+	if err := database.DB.Where("user_id = ?", userID).Order("period, scope, category").Find(&budgets).Error; err != nil {
+		utils.LogError("GetBudgets: DB Find failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load budgets: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"budgets": budgets})
+}
+
+// CreateBudget adds a budget
+func CreateBudget(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var input BudgetInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	settings, err := loadUserSettings(userID)
+	if err != nil {
+		utils.LogError("CreateBudget: loadUserSettings failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load settings: " + err.Error()})
+		return
+	}
+
+	budget := models.Budget{UserID: userID}
+	if err := input.apply(&budget, settings.BaseCurrency); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = database.DB.Create(&budget).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("A %s budget for %q already exists", budget.Period, budget.Category)})
+		return
+	}
+	if err != nil {
+		utils.LogError("CreateBudget: DB Create failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create budget: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"budget": budget})
+}
+
+// UpdateBudget replaces a budget
+func UpdateBudget(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	budget, ok := findBudget(c, userID)
+	if !ok {
+		return
+	}
+
+	var input BudgetInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	settings, err := loadUserSettings(userID)
+	if err != nil {
+		utils.LogError("UpdateBudget: loadUserSettings failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load settings: " + err.Error()})
+		return
+	}
+	if err := input.apply(&budget, settings.BaseCurrency); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = database.DB.Save(&budget).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("A %s budget for %q already exists", budget.Period, budget.Category)})
+		return
+	}
+	if err != nil {
+		utils.LogError("UpdateBudget: DB Save failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update budget: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"budget": budget})
+}
+
+// DeleteBudget removes a budget
+func DeleteBudget(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	budget, ok := findBudget(c, userID)
+	if !ok {
+		return
+	}
+
+	if err := database.DB.Delete(&budget).Error; err != nil {
+		utils.LogError("DeleteBudget: DB Delete failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete budget: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Budget deleted"})
+}
+
+// GetBudgetStatus reports spending against every budget in its current period
+func GetBudgetStatus(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	settings, err := loadUserSettings(userID)
+	if err != nil {
+		utils.LogError("GetBudgetStatus: loadUserSettings failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load settings: " + err.Error()})
+		return
+	}
+	statuses, missing, err := loadBudgetStatuses(settings)
+	if err != nil {
+		utils.LogError("GetBudgetStatus: loadBudgetStatuses failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to evaluate budgets: " + err.Error()})
+		return
+	}
+
+	items := make([]gin.H, 0, len(statuses))
+	for _, s := range statuses {
+		items = append(items, s.JSON(settings.BaseCurrency))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"budgets":         items,
+		"today":           settings.Now().Format(utils.StoredDateLayout),
+		"base_currency":   settings.BaseCurrency,
+		"month_start_day": settings.MonthStartDay,
+		"missing_rates":   missing,
+	})
+}
+
+func findBudget(c *gin.Context, userID uuid.UUID) (models.Budget, bool) {
+	var budget models.Budget
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid budget id"})
+		return budget, false
+	}
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&budget).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Budget not found"})
+		} else {
+			utils.LogError("findBudget: DB First failed", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load budget: " + err.Error()})
+		}
+		return budget, false
+	}
+	return budget, true
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"ledger-lens/backend/database"
+	"ledger-lens/backend/models"
+	"ledger-lens/backend/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// budgetOverThreshold is the percentage at which a budget is spent
+const budgetOverThreshold = 100
+
+// budgetCrossing is a threshold a budget has reached in its current period
+type budgetCrossing struct {
+	Status    BudgetStatus
+	Threshold int
+}
+
+// budgetCrossings lists the thresholds each budget has reached under the
+// user's alert preferences, lowest first
+func budgetCrossings(statuses []BudgetStatus, settings models.UserSettings) []budgetCrossing {
+	var thresholds []int
+	switch settings.BudgetAlerts {
+	case models.BudgetAlertsOff:
+		return nil
+	case models.BudgetAlertsOver:
+		thresholds = []int{budgetOverThreshold}
+	default:
+		thresholds = []int{settings.BudgetWarnPercent, budgetOverThreshold}
+	}
+
+	var out []budgetCrossing
+	for _, s := range statuses {
+		if !s.Converted {
+			continue
+		}
+		percent := s.Percent()
+		for _, threshold := range thresholds {
+			if (percent != nil && *percent >= float64(threshold)) || (percent == nil && s.Spent > 0) {
+				out = append(out, budgetCrossing{s, threshold})
+			}
+		}
+	}
+	return out
+}
+
+var budgetPeriodLabels = map[string]string{
+	models.BudgetPeriodWeek:  "本週",
+	models.BudgetPeriodMonth: "本月",
+	models.BudgetPeriodYear:  "今年",
+}
+
+// formatBudgetAlert renders crossings for a LINE push, one line per budget, e.g.
+// 「餐飲」本月預算已用 85%：已花費 8,500 / 10,000 TWD，剩餘 1,500。
+// The 100% threshold reads 已超支 only when spending is over the budget.
+func formatBudgetAlert(crossings []budgetCrossing, currency string) string {
+	lines := []string{"預算提醒"}
+	for _, c := range crossings {
+		s := c.Status
+		label := fmt.Sprintf("「%s」%s預算", s.Budget.Category, budgetPeriodLabels[s.Budget.Period])
+		spent := fmt.Sprintf("已花費 %s / %s %s", formatLineAmount(s.Spent, currency), formatLineAmount(s.Available(), currency), currency)
+		if c.Threshold >= budgetOverThreshold && s.OverBudget() {
+			lines = append(lines, fmt.Sprintf("%s已超支：%s，超出 %s。", label, spent, formatLineAmount(s.Remaining().Abs(), currency)))
+			continue
+		}
+		if c.Threshold >= budgetOverThreshold {
+			// Spent exactly the budget: reached but, as in the status API, not over
+			lines = append(lines, fmt.Sprintf("「%s」%s已達預算：%s，剩餘 %s。", s.Budget.Category, budgetPeriodLabels[s.Budget.Period], spent, formatLineAmount(s.Remaining(), currency)))
+			continue
+		}
+		percent := 0.0
+		if p := s.Percent(); p != nil {
+			percent = *p
+		}
+		lines = append(lines, fmt.Sprintf("%s已用 %.0f%%：%s，剩餘 %s。", label, percent, spent, formatLineAmount(s.Remaining(), currency)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// checkBudgetAlerts pushes a LINE message for every budget threshold newly
+// reached in the current period. Thresholds are claimed before sending so
+// concurrent checks push each once per period; a failed push releases them
+// for the next check to retry.
+func checkBudgetAlerts(userID uuid.UUID) error {
+	var user models.User
+	if err := database.DB.Select("id", "line_user_id").First(&user, "id = ?", userID).Error; err != nil {
+		return err
+	}
+	if user.LineUserID == "" {
+		return nil
+	}
+	settings, err := loadUserSettings(userID)
+	if err != nil {
+		return err
+	}
+	if settings.BudgetAlerts == models.BudgetAlertsOff {
+		return nil
+	}
+	statuses, _, err := loadBudgetStatuses(settings)
+	if err != nil {
+		return err
+	}
+
+	// Keep the highest new threshold per budget; a jump past both only reports going over
+	var fresh []budgetCrossing
+	var claimed []uint
+	index := map[uuid.UUID]int{}
+	for _, c := range budgetCrossings(statuses, settings) {
+		alert := models.BudgetAlert{BudgetID: c.Status.Budget.ID, PeriodFrom: c.Status.From, Threshold: c.Threshold}
+		res := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&alert)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			continue
+		}
+		claimed = append(claimed, alert.ID)
+		if i, ok := index[alert.BudgetID]; ok {
+			fresh[i] = c
+			continue
+		}
+		index[alert.BudgetID] = len(fresh)
+		fresh = append(fresh, c)
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
+	if err := pushLineText(user.LineUserID, formatBudgetAlert(fresh, settings.BaseCurrency)); err != nil {
+		if delErr := database.DB.Delete(&models.BudgetAlert{}, claimed).Error; delErr != nil {
+			utils.LogError("checkBudgetAlerts: releasing unsent alerts failed", delErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// notifyBudgetAlerts checks budgets after the ledger changed without holding
+// up the response
+func notifyBudgetAlerts(userID uuid.UUID) {
+	go func() {
+		if err := checkBudgetAlerts(userID); err != nil {
+			utils.LogError("notifyBudgetAlerts: checkBudgetAlerts failed", err)
+		}
+	}()
+}
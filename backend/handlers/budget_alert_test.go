@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"testing"
+
+	"ledger-lens/backend/models"
+
+	"github.com/google/uuid"
+)
+
+func TestBudgetCrossings(t *testing.T) {
+	status := func(category string, spent models.Money) BudgetStatus {
+		return BudgetStatus{
+			Budget:    models.Budget{ID: uuid.New(), Category: category, Period: models.BudgetPeriodMonth, Currency: "TWD"},
+			From:      "20240301",
+			Limit:     1000,
+			Spent:     spent,
+			Converted: true,
+		}
+	}
+	statuses := []BudgetStatus{status("餐飲", 850), status("交通", 1200), status("娛樂", 100)}
+	settings := models.DefaultUserSettings(uuid.New())
+
+	count := func(s models.UserSettings) map[string][]int {
+		got := map[string][]int{}
+		for _, c := range budgetCrossings(statuses, s) {
+			got[c.Status.Budget.Category] = append(got[c.Status.Budget.Category], c.Threshold)
+		}
+		return got
+	}
+
+	got := count(settings)
+	if len(got["餐飲"]) != 1 || got["餐飲"][0] != 80 || len(got["交通"]) != 2 || len(got["娛樂"]) != 0 {
+		t.Errorf("crossings = %v", got)
+	}
+	settings.BudgetAlerts = models.BudgetAlertsOver
+	if got := count(settings); len(got) != 1 || got["交通"][0] != 100 {
+		t.Errorf("over-only crossings = %v", got)
+	}
+	settings.BudgetAlerts = models.BudgetAlertsOff
+	if got := count(settings); len(got) != 0 {
+		t.Errorf("crossings with alerts off = %v", got)
+	}
+}
+
+func TestFormatBudgetAlert(t *testing.T) {
+	budget := models.Budget{Category: "餐飲", Period: models.BudgetPeriodMonth, Currency: "TWD"}
+	text := formatBudgetAlert([]budgetCrossing{
+		{BudgetStatus{Budget: budget, Limit: 10000, Spent: 8500, Converted: true}, 80},
+		{BudgetStatus{Budget: budget, Limit: 10000, Spent: 10250, Converted: true}, 100},
+		{BudgetStatus{Budget: budget, Limit: 10000, Spent: 10000, Converted: true}, 100},
+	}, "TWD")
+	want := "預算提醒\n" +
+		"「餐飲」本月預算已用 85%：已花費 8,500 / 10,000 TWD，剩餘 1,500。\n" +
+		"「餐飲」本月預算已超支：已花費 10,250 / 10,000 TWD，超出 250。\n" +
+		"「餐飲」本月已達預算：已花費 10,000 / 10,000 TWD，剩餘 0。"
+	if text != want {
+		t.Errorf("formatBudgetAlert =\n%s\nwant\n%s", text, want)
+	}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"ledger-lens/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestEvaluateBudget(t *testing.T) {
+	converter := &currencyConverter{base: "TWD"}
+	today := time.Date(2024, 3, 10, 21, 0, 0, 0, time.UTC)
+	rows := []models.Transaction{
+		{Date: "20240110", Type: "支", Category: "午餐", MainCategory: "餐飲", Amount: 1200, Currency: "TWD"},
+		{Date: "20240220", Type: "支", Category: "午餐", MainCategory: "餐飲", Amount: 700, Currency: "TWD"},
+		{Date: "20240302", Type: "支", Category: "晚餐", MainCategory: "餐飲", Amount: 310, Currency: "TWD"},
+		{Date: "20240303", Type: "收", Category: "退款", MainCategory: "餐飲", Amount: 500, Currency: "TWD"},
+		{Date: "20240304", Type: "支", Category: "公車", MainCategory: "交通", Amount: 50, Currency: "TWD"},
+	}
+	budget := models.Budget{
+		Scope:     models.BudgetScopeMainCategory,
+		Category:  "餐飲",
+		Period:    models.BudgetPeriodMonth,
+		Amount:    1000,
+		Currency:  "TWD",
+		CreatedAt: time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC),
+	}
+
+	cases := []struct {
+		rollover string
+		carried  models.Money
+	}{
+		{models.RolloverNone, 0},
+		{models.RolloverUnspent, 300}, // January's overspend is forgiven
+		{models.RolloverAll, 100},     // -200 + 300
+	}
+	for _, tc := range cases {
+		budget.Rollover = tc.rollover
+		s := evaluateBudget(budget, rows, converter, today, 1, map[string]bool{})
+		if s.From != "20240301" || s.To != "20240331" {
+			t.Errorf("%s: period = %s..%s", tc.rollover, s.From, s.To)
+		}
+		if s.Spent != 310 || s.Carried != tc.carried || s.Limit != 1000 {
+			t.Errorf("%s: spent %d, carried %d, limit %d; want 310, %d, 1000", tc.rollover, s.Spent, s.Carried, s.Limit, tc.carried)
+		}
+		if s.Projected != 961 { // 310 over 10 of 31 days
+			t.Errorf("%s: projected = %d, want 961", tc.rollover, s.Projected)
+		}
+	}
+
+	budget.Scope, budget.Category, budget.Rollover, budget.Amount = models.BudgetScopeCategory, "晚餐", models.RolloverNone, 300
+	s := evaluateBudget(budget, rows, converter, today, 1, map[string]bool{})
+	if !s.OverBudget() || s.Remaining() != -10 || *s.Percent() != 103.3 {
+		t.Errorf("晚餐 status = %+v", s)
+	}
+}
+
+func TestBudgetPeriodsFiscal(t *testing.T) {
+	budget := models.Budget{Period: models.BudgetPeriodMonth, Rollover: models.RolloverAll, CreatedAt: time.Date(2024, 1, 20, 0, 0, 0, 0, time.UTC)}
+	periods := budgetPeriods(budget, time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC), 25)
+	var got []string
+	for _, p := range periods {
+		got = append(got, p.From)
+	}
+	want := []string{"20231225", "20240125", "20240225"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("fiscal budget periods start %v, want %v", got, want)
+	}
+}
+
+func TestBudgetDuplicateTarget(t *testing.T) {
+	useFailingWritesDB(t)
+	body := `{"category": "餐飲", "period": "month", "amount": "5000"}`
+
+	if w := callHandler(CreateBudget, http.MethodPost, body); w.Code != http.StatusConflict {
+		t.Errorf("create: status %d, want 409: %s", w.Code, w.Body)
+	}
+	id := gin.Param{Key: "id", Value: uuid.NewString()}
+	if w := callHandler(UpdateBudget, http.MethodPut, body, id); w.Code != http.StatusConflict {
+		t.Errorf("update: status %d, want 409: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"ledger-lens/backend/models"
+	"ledger-lens/backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxMovers is how many categories are listed as top movers
+const maxMovers = 5
+
+// Baselines for /reports/compare when no explicit previous range is given
+const (
+	AgainstPrevious = "previous"  // the range of equal length just before
+	AgainstLastYear = "last_year" // the same range one year earlier
+)
+
+// Change is one figure in two periods
+type Change struct {
+	Current  models.Money
+	Previous models.Money
+}
+
+func (c Change) Delta() models.Money {
+	return c.Current - c.Previous
+}
+
+// Percent is the change relative to the previous period, rounded to one
+// decimal. It is nil when the previous period has nothing to compare with.
+func (c Change) Percent() *float64 {
+	if c.Previous == 0 {
+		return nil
+	}
+	p := math.Round(float64(c.Delta())/math.Abs(float64(c.Previous))*1000) / 10
+	return &p
+}
+
+func (c Change) JSON(currency string) gin.H {
+	return gin.H{
+		"current":  money(c.Current, currency),
+		"previous": money(c.Previous, currency),
+		"delta":    money(c.Delta(), currency),
+		"change":   c.Percent(),
+	}
+}
+
+// CategoryChange compares one category across the two periods
+type CategoryChange struct {
+	Category string
+	Expense  Change
+	Income   Change
+}
+
+func (c CategoryChange) JSON(currency string) gin.H {
+	return gin.H{
+		"category": c.Category,
+		"expense":  c.Expense.JSON(currency),
+		"income":   c.Income.JSON(currency),
+	}
+}
+
+// Comparison is the result of compareSummaries
+type Comparison struct {
+	Current, Previous *Summary
+	Expense, Income   Change
+	Categories        []CategoryChange // by current expense, largest first
+	Movers            []CategoryChange // by absolute change in expense
+}
+
+// compareSummaries lines up two category summaries in the same base currency
+func compareSummaries(current, previous *Summary) *Comparison {
+	cmp := &Comparison{
+		Current:  current,
+		Previous: previous,
+		Expense:  Change{current.Totals.Expense, previous.Totals.Expense},
+		Income:   Change{current.Totals.Income, previous.Totals.Income},
+	}
+
+	byCategory := map[string]*CategoryChange{}
+	get := func(key string) *CategoryChange {
+		c, ok := byCategory[key]
+		if !ok {
+			c = &CategoryChange{Category: key}
+			byCategory[key] = c
+		}
+		return c
+	}
+	for _, g := range current.Groups {
+		c := get(g.Key)
+		c.Expense.Current, c.Income.Current = g.Totals.Expense, g.Totals.Income
+	}
+	for _, g := range previous.Groups {
+		c := get(g.Key)
+		c.Expense.Previous, c.Income.Previous = g.Totals.Expense, g.Totals.Income
+	}
+
+	for _, c := range byCategory {
+		cmp.Categories = append(cmp.Categories, *c)
+	}
+	sort.Slice(cmp.Categories, func(i, j int) bool {
+		a, b := cmp.Categories[i], cmp.Categories[j]
+		if a.Expense.Current != b.Expense.Current {
+			return a.Expense.Current > b.Expense.Current
+		}
+		if a.Income.Current != b.Income.Current {
+			return a.Income.Current > b.Income.Current
+		}
+		return a.Category < b.Category
+	})
+
+	for _, c := range cmp.Categories {
+		if c.Expense.Delta() != 0 {
+			cmp.Movers = append(cmp.Movers, c)
+		}
+	}
+	sort.SliceStable(cmp.Movers, func(i, j int) bool {
+		return cmp.Movers[i].Expense.Delta().Abs() > cmp.Movers[j].Expense.Delta().Abs()
+	})
+	if len(cmp.Movers) > maxMovers {
+		cmp.Movers = cmp.Movers[:maxMovers]
+	}
+	return cmp
+}
+
+// JSON renders the comparison for the API
+func (cmp *Comparison) JSON() gin.H {
+	currency := cmp.Current.BaseCurrency
+	categories := make([]gin.H, 0, len(cmp.Categories))
+	for _, c := range cmp.Categories {
+		categories = append(categories, c.JSON(currency))
+	}
+	movers := make([]gin.H, 0, len(cmp.Movers))
+	for _, c := range cmp.Movers {
+		movers = append(movers, c.JSON(currency))
+	}
+
+	missing := map[string]bool{}
+	rates := []string{}
+	for _, cur := range append(append([]string{}, cmp.Current.MissingRates...), cmp.Previous.MissingRates...) {
+		if !missing[cur] {
+			missing[cur] = true
+			rates = append(rates, cur)
+		}
+	}
+	sort.Strings(rates)
+
+	return gin.H{
+		"base_currency":   currency,
+		"month_start_day": cmp.Current.MonthStartDay,
+		"totals": gin.H{
+			"expense": cmp.Expense.JSON(currency),
+			"income":  cmp.Income.JSON(currency),
+			"net":     Change{cmp.Current.Totals.Net(), cmp.Previous.Totals.Net()}.JSON(currency),
+			"count":   gin.H{"current": cmp.Current.Totals.Count, "previous": cmp.Previous.Totals.Count},
+		},
+		"categories":    categories,
+		"movers":        movers,
+		"unconverted":   cmp.Current.Unconverted + cmp.Previous.Unconverted,
+		"missing_rates": rates,
+	}
+}
+
+// baselineRange picks the period to compare from..to against. Ranges made of
+// whole months (starting on monthStartDay) move by months, so March is
+// compared with February rather than with the 31 days before it.
+func baselineRange(from, to, against string, monthStartDay int) (string, string, error) {
+	start, err := time.Parse(utils.StoredDateLayout, from)
+	if err != nil {
+		return "", "", err
+	}
+	end, err := time.Parse(utils.StoredDateLayout, to)
+	if err != nil {
+		return "", "", err
+	}
+	day := func(t time.Time) string { return t.Format(utils.StoredDateLayout) }
+
+	next := end.AddDate(0, 0, 1)
+	wholeMonths := start.Day() == monthStartDay && next.Day() == monthStartDay
+	months := (next.Year()-start.Year())*12 + int(next.Month()-start.Month())
+
+	switch against {
+	case AgainstPrevious:
+		if wholeMonths {
+			s := start.AddDate(0, -months, 0)
+			return day(s), day(start.AddDate(0, 0, -1)), nil
+		}
+		days := int(end.Sub(start).Hours()/24) + 1
+		return day(start.AddDate(0, 0, -days)), day(start.AddDate(0, 0, -1)), nil
+	case AgainstLastYear:
+		if wholeMonths {
+			s := start.AddDate(-1, 0, 0)
+			return day(s), day(s.AddDate(0, months, -1)), nil
+		}
+		return day(yearEarlier(start)), day(yearEarlier(end)), nil
+	}
+	return "", "", fmt.Errorf("%w: against must be %s or %s", errInvalidQuery, AgainstPrevious, AgainstLastYear)
+}
+
+// yearEarlier moves a date back one year, turning 29 February into the 28th
+func yearEarlier(t time.Time) time.Time {
+	y := t.AddDate(-1, 0, 0)
+	if y.Month() != t.Month() {
+		y = y.AddDate(0, 0, -y.Day())
+	}
+	return y
+}
+
+// CompareReports compares two periods by category in the user's base currency.
+// Query: from, to (or window) for the current period, and either
+// previousFrom and previousTo, or against=previous|last_year (previous by default).
+func CompareReports(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	settings, err := loadUserSettings(userID)
+	if err != nil {
+		utils.LogError("CompareReports: loadUserSettings failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load settings: " + err.Error()})
+		return
+	}
+	from, to, err := parseReportRange(c, settings)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if from == "" || to == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to (or window) are required"})
+		return
+	}
+
+	var prevFrom, prevTo string
+	if c.Query("previousFrom") != "" || c.Query("previousTo") != "" {
+		if c.Query("against") != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "use either against or previousFrom/previousTo"})
+			return
+		}
+		if prevFrom, err = parseQueryDate("previousFrom", c.Query("previousFrom")); err == nil {
+			prevTo, err = parseQueryDate("previousTo", c.Query("previousTo"))
+		}
+		if err == nil && (prevFrom == "" || prevTo == "" || prevFrom > prevTo) {
+			err = fmt.Errorf("%w: previousFrom and previousTo must both be set, in order", errInvalidQuery)
+		}
+	} else {
+		prevFrom, prevTo, err = baselineRange(from, to, c.DefaultQuery("against", AgainstPrevious), settings.MonthStartDay)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	current, err := loadSummary(settings, TransactionFilter{From: from, To: to}, GroupByCategory)
+	if err != nil {
+		utils.LogError("CompareReports: loadSummary current failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build comparison: " + err.Error()})
+		return
+	}
+	previous, err := loadSummary(settings, TransactionFilter{From: prevFrom, To: prevTo}, GroupByCategory)
+	if err != nil {
+		utils.LogError("CompareReports: loadSummary previous failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build comparison: " + err.Error()})
+		return
+	}
+
+	body := compareSummaries(current, previous).JSON()
+	body["current"] = gin.H{"from": from, "to": to}
+	body["previous"] = gin.H{"from": prevFrom, "to": prevTo}
+	c.JSON(http.StatusOK, body)
+}
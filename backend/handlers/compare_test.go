@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"testing"
+
+	"ledger-lens/backend/models"
+)
+
+func TestCompareSummaries(t *testing.T) {
+	group := func(key string, expense, income int64) *SummaryGroup {
+		return &SummaryGroup{Key: key, Totals: ReportTotals{Expense: models.Money(expense), Income: models.Money(income)}}
+	}
+	current := &Summary{BaseCurrency: "TWD", Totals: ReportTotals{Expense: 900, Income: 500}, Groups: []*SummaryGroup{
+		group("餐飲", 600, 0), group("交通", 300, 0), group("薪水", 0, 500),
+	}}
+	previous := &Summary{BaseCurrency: "TWD", Totals: ReportTotals{Expense: 700}, Groups: []*SummaryGroup{
+		group("餐飲", 200, 0), group("娛樂", 500, 0),
+	}}
+
+	cmp := compareSummaries(current, previous)
+	if cmp.Expense.Delta() != 200 || *cmp.Expense.Percent() != 28.6 {
+		t.Errorf("expense change = %d, %v", cmp.Expense.Delta(), *cmp.Expense.Percent())
+	}
+	if cmp.Income.Percent() != nil {
+		t.Errorf("income change from nothing = %v, want nil", *cmp.Income.Percent())
+	}
+	if len(cmp.Categories) != 4 || cmp.Categories[0].Category != "餐飲" {
+		t.Fatalf("categories = %+v", cmp.Categories)
+	}
+
+	var movers []string
+	for _, m := range cmp.Movers {
+		movers = append(movers, m.Category)
+	}
+	want := []string{"娛樂", "餐飲", "交通"} // -500, +400, +300; 薪水 has no expense change
+	if len(movers) != len(want) {
+		t.Fatalf("movers = %v, want %v", movers, want)
+	}
+	for i := range want {
+		if movers[i] != want[i] {
+			t.Errorf("movers = %v, want %v", movers, want)
+			break
+		}
+	}
+	if p := cmp.Movers[0].Expense.Percent(); p == nil || *p != -100 {
+		t.Errorf("娛樂 change = %v, want -100", p)
+	}
+}
+
+func TestBaselineRange(t *testing.T) {
+	cases := []struct {
+		from, to, against string
+		monthStartDay     int
+		wantFrom, wantTo  string
+	}{
+		{"20240301", "20240331", AgainstPrevious, 1, "20240201", "20240229"},
+		{"20240101", "20240331", AgainstPrevious, 1, "20231001", "20231231"},
+		{"20240310", "20240316", AgainstPrevious, 1, "20240303", "20240309"},
+		{"20240201", "20240229", AgainstLastYear, 1, "20230201", "20230228"},
+		{"20240220", "20240229", AgainstLastYear, 1, "20230220", "20230228"},
+		{"20240225", "20240324", AgainstPrevious, 25, "20240125", "20240224"},
+		{"20240225", "20240324", AgainstLastYear, 25, "20230225", "20230324"},
+		{"20240301", "20240331", AgainstPrevious, 25, "20240130", "20240229"}, // not a fiscal month
+	}
+	for _, tc := range cases {
+		from, to, err := baselineRange(tc.from, tc.to, tc.against, tc.monthStartDay)
+		if err != nil || from != tc.wantFrom || to != tc.wantTo {
+			t.Errorf("baselineRange(%s, %s, %s) = %s, %s, %v; want %s, %s", tc.from, tc.to, tc.against, from, to, err, tc.wantFrom, tc.wantTo)
+		}
+	}
+	if _, _, err := baselineRange("20240101", "20240131", "decade", 1); err == nil {
+		t.Error("unknown baseline accepted")
+	}
+}
@@ -0,0 +1,433 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"ledger-lens/backend/models"
+	"ledger-lens/backend/utils"
+)
+
+// Diagnostic severities
+const (
+	SeverityError   = "error"   // the row was not imported
+	SeverityWarning = "warning" // the row was imported (or harmlessly skipped) with a caveat
+)
+
+// Diagnostic codes
+const (
+	DiagColumnCount   = "column_count"
+	DiagMissingValue  = "missing_value"
+	DiagInvalidAmount = "invalid_amount"
+	DiagRoundedAmount = "rounded_amount"
+	DiagInvalidDate   = "invalid_date"
+	DiagAmbiguousDate = "ambiguous_date"
+	DiagUnknownType   = "unknown_type"
+	DiagUnknownColumn = "unknown_column"
+	DiagEmptyRow      = "empty_row"
+)
+
+// ImportDiagnostic describes one problem found while reading import data
+type ImportDiagnostic struct {
+	Line     int      `json:"line"` // 1-based CSV line, or position in a JSON array
+	Record   []string `json:"record,omitempty"`
+	Field    string   `json:"field,omitempty"`
+	Code     string   `json:"code"`
+	Reason   string   `json:"reason"`
+	Severity string   `json:"severity"`
+}
+
+// ImportReport collects the per-row outcome of parsing
+type ImportReport struct {
+	TotalRows   int                `json:"total_rows"`
+	ValidRows   int                `json:"valid_rows"`
+	FailedRows  int                `json:"failed_rows"`
+	Diagnostics []ImportDiagnostic `json:"diagnostics"`
+	// FailedUUIDs are the source UUIDs of rejected rows. An overwrite keeps
+	// their stored versions instead of deleting them.
+	FailedUUIDs []string `json:"-"`
+	// Per-currency sums of the valid rows, to check against the source
+	ExpenseTotals models.Totals `json:"expense_totals"`
+	IncomeTotals  models.Totals `json:"income_totals"`
+}
+
+func newImportReport() *ImportReport {
+	return &ImportReport{Diagnostics: []ImportDiagnostic{}, ExpenseTotals: models.Totals{}, IncomeTotals: models.Totals{}}
+}
+
+func (r *ImportReport) add(d ImportDiagnostic) {
+	r.Diagnostics = append(r.Diagnostics, d)
+}
+
+// fail counts a rejected row, remembering its source UUID if it has one
+func (r *ImportReport) fail(sourceUUID string) {
+	r.FailedRows++
+	if sourceUUID != "" {
+		r.FailedUUIDs = append(r.FailedUUIDs, sourceUUID)
+	}
+}
+
+// accept counts a row that will be imported
+func (r *ImportReport) accept(t models.Transaction) {
+	r.ValidRows++
+	switch t.Type {
+	case "支":
+		r.ExpenseTotals.Add(t.Currency, t.Amount)
+	case "收":
+		r.IncomeTotals.Add(t.Currency, t.Amount)
+	}
+}
+
+// Errors returns the diagnostics that caused rows to be rejected
+func (r *ImportReport) Errors() []ImportDiagnostic {
+	var errs []ImportDiagnostic
+	for _, d := range r.Diagnostics {
+		if d.Severity == SeverityError {
+			errs = append(errs, d)
+		}
+	}
+	return errs
+}
+
+// ColumnMapping records which field a header column was mapped to.
+// Field is empty for ignored columns.
+type ColumnMapping struct {
+	Header string `json:"header"`
+	Field  string `json:"field"`
+}
+
+// ParseResult is the output of parseCSV
+type ParseResult struct {
+	Rows     []models.Transaction
+	Encoding string // encoding the file was decoded from
+	Profile  string // ID of the mapping profile used
+	Columns  []ColumnMapping
+	Report   *ImportReport
+}
+
+// ParseOptions selects how header columns are interpreted
+type ParseOptions struct {
+	Profile    *mappingProfile  // explicit choice; nil means auto-detect
+	Candidates []mappingProfile // considered when auto-detecting, in priority order
+	Encoding   string           // override from parseEncoding; empty means detect
+}
+
+// parseCSV reads an exported ledger. Rows that cannot be imported are left out
+// and recorded in the report instead of being coerced into zero values.
+func parseCSV(r io.Reader, opts ParseOptions) (*ParseResult, error) {
+	decoded, enc, err := decodeToUTF8(r, opts.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(decoded)
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1 // column count is checked per row below
+
+	// Read Header
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	// Remove BOM if present
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	profile := opts.Profile
+	if profile == nil {
+		candidates := opts.Candidates
+		if len(candidates) == 0 {
+			candidates = builtInProfiles
+		}
+		if profile, err = detectProfile(header, candidates); err != nil {
+			return nil, err
+		}
+	} else if profile.score(header) < 0 {
+		return nil, fmt.Errorf("profile %q does not match the header row; missing date or amount column", profile.Name)
+	}
+
+	dateLayout := ""
+	if profile.DateFormat != "" {
+		if dateLayout, err = utils.DateLayout(profile.DateFormat); err != nil {
+			return nil, err
+		}
+	}
+
+	report := newImportReport()
+	result := &ParseResult{Encoding: enc, Profile: profile.ID, Report: report}
+	fields := make([]string, len(header))
+	for i, h := range header {
+		fields[i] = profile.field(h)
+		result.Columns = append(result.Columns, ColumnMapping{Header: h, Field: fields[i]})
+		if fields[i] == "" {
+			report.add(ImportDiagnostic{
+				Line:     1,
+				Field:    h,
+				Code:     DiagUnknownColumn,
+				Reason:   fmt.Sprintf("column %q is not recognized and was ignored", h),
+				Severity: SeverityWarning,
+			})
+		}
+	}
+
+	for {
+		// With LazyQuotes and a variable field count the reader reports no
+		// parse errors, so any error here comes from the underlying file
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		if isBlankRecord(record) {
+			report.add(ImportDiagnostic{
+				Line:     line,
+				Record:   record,
+				Code:     DiagEmptyRow,
+				Reason:   "empty row skipped",
+				Severity: SeverityWarning,
+			})
+			continue
+		}
+
+		report.TotalRows++
+		if len(record) != len(header) {
+			report.fail(recordUUID(fields, record))
+			report.add(ImportDiagnostic{
+				Line:     line,
+				Record:   record,
+				Code:     DiagColumnCount,
+				Reason:   fmt.Sprintf("expected %d columns, got %d", len(header), len(record)),
+				Severity: SeverityError,
+			})
+			continue
+		}
+
+		row, diags := parseRecord(profile, dateLayout, fields, record)
+		failed := false
+		for _, d := range diags {
+			d.Line = line
+			d.Record = record
+			report.add(d)
+			failed = failed || d.Severity == SeverityError
+		}
+		if failed {
+			report.fail(row.SourceUUID)
+			continue
+		}
+
+		report.accept(row)
+		result.Rows = append(result.Rows, row)
+	}
+
+	return result, nil
+}
+
+// recordUUID reads the UUID column of a record that may be short
+func recordUUID(fields []string, record []string) string {
+	for i, field := range fields {
+		if field == "uuid" && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+	}
+	return ""
+}
+
+// parseRecord maps one CSV record onto a transaction
+func parseRecord(profile *mappingProfile, dateLayout string, fields []string, record []string) (models.Transaction, []ImportDiagnostic) {
+	var row models.Transaction
+	var diags []ImportDiagnostic
+	// Amounts are parsed once the currency, which sets their precision, is known
+	amounts := map[string]string{}
+
+	for i, field := range fields {
+		value := strings.TrimSpace(record[i])
+		switch field {
+		case "date":
+			row.Date = value
+			if dateLayout == "" || value == "" {
+				continue // normalised by validateTransaction
+			}
+			row.DateRaw = value
+			if t, err := time.Parse(dateLayout, value); err == nil {
+				row.Date = t.Format(utils.StoredDateLayout)
+			} else {
+				diags = append(diags, ImportDiagnostic{
+					Field:    "date",
+					Code:     DiagInvalidDate,
+					Reason:   fmt.Sprintf("date %q does not match format %s", value, profile.DateFormat),
+					Severity: SeverityError,
+				})
+			}
+		case "category":
+			row.Category = value
+		case "mainCategory":
+			row.MainCategory = value
+		case "amount", "expenseAmount", "incomeAmount":
+			if value != "" || field == "amount" {
+				amounts[field] = value
+			}
+		case "currency":
+			row.Currency = value
+		case "member":
+			row.Member = value
+		case "account":
+			row.Account = value
+		case "tags":
+			row.Tags = value
+		case "note":
+			// Several columns may feed the note, e.g. 摘要 and 備註
+			if value != "" && row.Note != "" {
+				row.Note += " " + value
+			} else if value != "" {
+				row.Note = value
+			}
+		case "type":
+			row.Type = value
+		case "lastUpdated":
+			row.LastUpdated = value
+		case "uuid":
+			row.SourceUUID = value
+		}
+	}
+
+	row.Currency = models.NormalizeCurrency(row.Currency)
+	parsed := map[string]models.Money{}
+	for _, field := range []string{"amount", "expenseAmount", "incomeAmount"} {
+		value, ok := amounts[field]
+		if !ok {
+			continue
+		}
+		v, d := parseAmount(field, value, row.Currency, profile.DecimalSeparator)
+		if d != nil {
+			diags = append(diags, *d)
+			if d.Severity == SeverityError {
+				continue
+			}
+		}
+		parsed[field] = v
+	}
+
+	amount, hasAmount := parsed["amount"]
+	expense, hasExpense := parsed["expenseAmount"]
+	income, hasIncome := parsed["incomeAmount"]
+	switch {
+	case hasAmount:
+		row.Amount = amount
+		applySignConvention(&row, profile.SignConvention)
+	case hasExpense && expense != 0:
+		row.Amount, row.Type = expense, "支"
+	case hasIncome:
+		row.Amount, row.Type = income, "收"
+	case hasExpense:
+		row.Amount, row.Type = 0, "支"
+	default:
+		if len(diags) == 0 {
+			diags = append(diags, ImportDiagnostic{Field: "amount", Code: DiagMissingValue, Reason: "amount is empty", Severity: SeverityError})
+		}
+	}
+
+	return row, append(diags, validateTransaction(&row)...)
+}
+
+// applySignConvention turns a signed amount into an unsigned amount and a type
+func applySignConvention(row *models.Transaction, convention string) {
+	if convention != models.SignNegativeExpense && convention != models.SignNegativeIncome {
+		return
+	}
+
+	negative := row.Amount < 0
+	row.Amount = row.Amount.Abs()
+	if row.Type != "" {
+		return
+	}
+	if negative == (convention == models.SignNegativeExpense) {
+		row.Type = "支"
+	} else {
+		row.Type = "收"
+	}
+}
+
+// parseAmount reads an amount in the currency's minor unit. Extra decimals are
+// rounded with a warning; anything that is not a number is an error.
+func parseAmount(field, value, currency, decimalSeparator string) (models.Money, *ImportDiagnostic) {
+	if value == "" {
+		return 0, &ImportDiagnostic{Field: field, Code: DiagMissingValue, Reason: "amount is empty", Severity: SeverityError}
+	}
+	m, err := models.ParseMoney(value, currency, decimalSeparator)
+	switch {
+	case errors.Is(err, models.ErrPrecisionLoss):
+		return m, &ImportDiagnostic{
+			Field:    field,
+			Code:     DiagRoundedAmount,
+			Reason:   fmt.Sprintf("amount %q rounded to %s %s", value, m.Format(currency), currency),
+			Severity: SeverityWarning,
+		}
+	case err != nil:
+		return 0, &ImportDiagnostic{
+			Field:    field,
+			Code:     DiagInvalidAmount,
+			Reason:   fmt.Sprintf("amount %q is not a number", value),
+			Severity: SeverityError,
+		}
+	}
+	return m, nil
+}
+
+// validateTransaction checks fields shared by every import channel and
+// normalises the date to StoredDateLayout, keeping the original text
+func validateTransaction(t *models.Transaction) []ImportDiagnostic {
+	var diags []ImportDiagnostic
+	t.Currency = models.NormalizeCurrency(t.Currency)
+	if t.Date == "" {
+		diags = append(diags, ImportDiagnostic{Field: "date", Code: DiagMissingValue, Reason: "date is empty", Severity: SeverityError})
+	} else if t.DateRaw == "" {
+		if d := normalizeTransactionDate(t); d != nil {
+			diags = append(diags, *d)
+		}
+	}
+	switch t.Type {
+	case "", "支", "收", "轉":
+	default:
+		diags = append(diags, ImportDiagnostic{
+			Field:    "type",
+			Code:     DiagUnknownType,
+			Reason:   fmt.Sprintf("type %q is not one of 支/收/轉", t.Type),
+			Severity: SeverityWarning,
+		})
+	}
+	return diags
+}
+
+// normalizeTransactionDate rewrites t.Date into canonical form
+func normalizeTransactionDate(t *models.Transaction) *ImportDiagnostic {
+	date, err := utils.NormalizeDate(t.Date)
+	if err != nil {
+		code := DiagInvalidDate
+		if errors.Is(err, utils.ErrAmbiguousDate) {
+			code = DiagAmbiguousDate
+		}
+		return &ImportDiagnostic{Field: "date", Code: code, Reason: err.Error(), Severity: SeverityError}
+	}
+	t.DateRaw = t.Date
+	t.Date = date.Format(utils.StoredDateLayout)
+	return nil
+}
+
+func isBlankRecord(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
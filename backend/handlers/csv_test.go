@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseCSVNormalizesDates(t *testing.T) {
+	csvContent := `日期,類別,金額,收支區分
+2023-12-01,Food,100,支
+2023/12/2,Food,200,支`
+
+	parsed, err := parseCSV(strings.NewReader(csvContent), ParseOptions{})
+	if err != nil {
+		t.Fatalf("Failed to parse CSV: %v", err)
+	}
+	if len(parsed.Rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %d", len(parsed.Rows))
+	}
+	for i, want := range []string{"20231201", "20231202"} {
+		if got := parsed.Rows[i].Date; got != want {
+			t.Errorf("Row %d: expected date %s, got %s", i+1, want, got)
+		}
+	}
+}
+
+func TestParseCSVReportsBadRows(t *testing.T) {
+	csvContent := `日期,類別,金額,收支區分
+2023-12-01,Food,100,支
+2023-12-02,Food,abc,支
+2023-12-03,Food
+,Food,50,支
+2023-12-04,Food,"1,200",支`
+
+	parsed, err := parseCSV(strings.NewReader(csvContent), ParseOptions{})
+	if err != nil {
+		t.Fatalf("Failed to parse CSV: %v", err)
+	}
+
+	if len(parsed.Rows) != 2 {
+		t.Fatalf("Expected 2 valid rows, got %d", len(parsed.Rows))
+	}
+	if parsed.Rows[1].Amount != 1200 {
+		t.Errorf("Expected amount 1200, got %v", parsed.Rows[1].Amount)
+	}
+
+	report := parsed.Report
+	if report.TotalRows != 5 || report.ValidRows != 2 || report.FailedRows != 3 {
+		t.Errorf("Unexpected counts: total=%d valid=%d failed=%d", report.TotalRows, report.ValidRows, report.FailedRows)
+	}
+
+	errs := report.Errors()
+	want := []struct {
+		line int
+		code string
+	}{
+		{3, DiagInvalidAmount},
+		{4, DiagColumnCount},
+		{5, DiagMissingValue},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("Expected %d errors, got %d: %+v", len(want), len(errs), errs)
+	}
+	for i, w := range want {
+		if errs[i].Line != w.line || errs[i].Code != w.code {
+			t.Errorf("Error %d: expected line %d %s, got line %d %s", i, w.line, w.code, errs[i].Line, errs[i].Code)
+		}
+	}
+
+	summary := formatImportReport(report)
+	if !strings.HasPrefix(summary, "已匯入 2 筆，3 筆失敗：第 3 行金額無法解析") {
+		t.Errorf("Unexpected summary: %s", summary)
+	}
+}
+
+func TestParseCSVRequiresAmountColumn(t *testing.T) {
+	if _, err := parseCSV(strings.NewReader("日期,類別\n2023-12-01,Food"), ParseOptions{}); err == nil {
+		t.Error("Expected error for missing amount column")
+	}
+}
+
+func TestParseCSVDetectsBankProfile(t *testing.T) {
+	csvContent := `交易日期,摘要,支出金額,存入金額,備註
+2024-01-05,ATM提款,"3,000",,
+2024-01-10,薪資轉入,,"52,000",一月`
+
+	parsed, err := parseCSV(strings.NewReader(csvContent), ParseOptions{})
+	if err != nil {
+		t.Fatalf("Failed to parse CSV: %v", err)
+	}
+	if parsed.Profile != "tw-bank" {
+		t.Errorf("Expected tw-bank profile, got %s", parsed.Profile)
+	}
+	if len(parsed.Rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %d: %+v", len(parsed.Rows), parsed.Report.Diagnostics)
+	}
+	if r := parsed.Rows[0]; r.Amount != 3000 || r.Type != "支" {
+		t.Errorf("Expected 3000 支, got %v %s", r.Amount, r.Type)
+	}
+	if r := parsed.Rows[1]; r.Amount != 52000 || r.Type != "收" || r.Note != "薪資轉入 一月" {
+		t.Errorf("Expected 52000 收 with joined note, got %v %s %q", r.Amount, r.Type, r.Note)
+	}
+}
+
+func TestParseCSVWithCustomProfile(t *testing.T) {
+	profile := &mappingProfile{
+		ID:               "custom",
+		Columns:          map[string]string{"Buchungstag": "date", "Betrag": "amount", "Währung": "currency", "Verwendungszweck": "note"},
+		DateFormat:       "DD.MM.YYYY",
+		DecimalSeparator: ",",
+		SignConvention:   "negative_is_expense",
+	}
+	csvContent := "Buchungstag,Betrag,Währung,Verwendungszweck\n03.02.2024,\"-1.234,50\",EUR,Miete\n"
+
+	parsed, err := parseCSV(strings.NewReader(csvContent), ParseOptions{Profile: profile})
+	if err != nil {
+		t.Fatalf("Failed to parse CSV: %v", err)
+	}
+	if len(parsed.Rows) != 1 {
+		t.Fatalf("Expected 1 row, got %d: %+v", len(parsed.Rows), parsed.Report.Diagnostics)
+	}
+	r := parsed.Rows[0]
+	if r.Date != "20240203" || r.Amount != 123450 || r.Currency != "EUR" || r.Type != "支" {
+		t.Errorf("Unexpected row: date=%s amount=%v %s type=%s", r.Date, r.Amount, r.Currency, r.Type)
+	}
+}
+
+func TestParseCSVRoundsExcessDecimals(t *testing.T) {
+	csvContent := "日期,類別,金額,貨幣,收支區分\n2024/01/05,咖啡,\"NT$ 85.5\",,支\n2024/01/06,書,(12.345),USD,支\n"
+
+	parsed, err := parseCSV(strings.NewReader(csvContent), ParseOptions{})
+	if err != nil {
+		t.Fatalf("Failed to parse CSV: %v", err)
+	}
+	if len(parsed.Rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %d: %+v", len(parsed.Rows), parsed.Report.Diagnostics)
+	}
+	if r := parsed.Rows[0]; r.Amount != 86 || r.Currency != "TWD" {
+		t.Errorf("Expected 86 TWD, got %v %s", r.Amount, r.Currency)
+	}
+	if r := parsed.Rows[1]; r.Amount != -1235 {
+		t.Errorf("Expected -1235 cents, got %v", r.Amount)
+	}
+	rounded := 0
+	for _, d := range parsed.Report.Diagnostics {
+		if d.Code == DiagRoundedAmount && d.Severity == SeverityWarning {
+			rounded++
+		}
+	}
+	if rounded != 2 {
+		t.Errorf("Expected 2 rounding warnings, got %+v", parsed.Report.Diagnostics)
+	}
+	if got := parsed.Report.ExpenseTotals["TWD"]; got != 86 {
+		t.Errorf("Expected TWD expense total 86, got %v", got)
+	}
+}
+
+func TestParseCSVStrayQuotes(t *testing.T) {
+	csvContent := "日期,類別,金額,備註,收支區分\n" +
+		"2023-12-01,Food,100,5\" screen,支\n" +
+		"2023-12-02,Food,200,\"unterminated,支"
+
+	parsed, err := parseCSV(strings.NewReader(csvContent), ParseOptions{})
+	if err != nil {
+		t.Fatalf("Failed to parse CSV: %v", err)
+	}
+	if len(parsed.Rows) != 1 || parsed.Rows[0].Note != `5" screen` {
+		t.Errorf("Expected the bare quote kept in the note, got %+v", parsed.Rows)
+	}
+	// The unterminated quote swallows the rest of the file into one field
+	if parsed.Report.FailedRows != 1 {
+		t.Errorf("Expected the unterminated row to fail, got %+v", parsed.Report)
+	}
+}
+
+func TestParseCSVReturnsReadErrors(t *testing.T) {
+	csvContent := "日期,類別,金額,收支區分\n2023-12-01,Food,100,支\n2023-12-02,Food,200,支\n"
+	_, err := parseCSV(newSizeLimitedReader(strings.NewReader(csvContent), int64(len(csvContent)-5)), ParseOptions{})
+	if !errors.Is(err, errFileTooLarge) {
+		t.Errorf("Expected errFileTooLarge, got %v", err)
+	}
+}
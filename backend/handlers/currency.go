@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"math/big"
+	"sort"
+
+	"ledger-lens/backend/models"
+
+	"gorm.io/gorm"
+)
+
+type datedRate struct {
+	date string // YYYYMMDD
+	rate *big.Rat
+}
+
+// currencyConverter converts amounts into a base currency using the rate on,
+// or most recently before, each transaction's date. Pairs are tried directly,
+// inverted, then crossed through any quoting base such as EUR.
+type currencyConverter struct {
+	base   string
+	rates  map[[2]string][]datedRate // {base, currency} sorted by date
+	pivots []string
+}
+
+// newCurrencyConverter loads the rates needed to convert the given currencies into base
+func newCurrencyConverter(db *gorm.DB, base string, currencies []string) (*currencyConverter, error) {
+	c := &currencyConverter{base: models.NormalizeCurrency(base), rates: map[[2]string][]datedRate{}}
+
+	needed := []string{c.base}
+	for _, cur := range currencies {
+		if cur = models.NormalizeCurrency(cur); cur != c.base {
+			needed = append(needed, cur)
+		}
+	}
+	if len(needed) == 1 {
+		return c, nil
+	}
+
+	var stored []models.ExchangeRate
+	err := db.Where("currency IN ? OR base IN ?", needed, needed).Order("date").Find(&stored).Error
+	if err != nil {
+		return nil, err
+	}
+
+	pivots := map[string]bool{}
+	for _, r := range stored {
+		rate, ok := new(big.Rat).SetString(r.Rate)
+		if !ok {
+			continue
+		}
+		key := [2]string{r.Base, r.Currency}
+		c.rates[key] = append(c.rates[key], datedRate{date: r.Date, rate: rate})
+		if !pivots[r.Base] {
+			pivots[r.Base] = true
+			c.pivots = append(c.pivots, r.Base)
+		}
+	}
+	sort.Strings(c.pivots)
+	return c, nil
+}
+
+// Convert returns the amount in the base currency, or false when no rate is known
+func (c *currencyConverter) Convert(m models.Money, currency, date string) (models.Money, bool) {
+	from := models.NormalizeCurrency(currency)
+	if from == c.base {
+		return m, true
+	}
+	rate := c.rate(from, c.base, date)
+	if rate == nil {
+		return 0, false
+	}
+	return m.Convert(from, c.base, rate), true
+}
+
+// rate returns the price of one unit of from in to on date
+func (c *currencyConverter) rate(from, to, date string) *big.Rat {
+	if r := c.lookup(from, to, date); r != nil {
+		return r
+	}
+	if r := c.lookup(to, from, date); r != nil {
+		return new(big.Rat).Inv(r)
+	}
+	for _, p := range c.pivots {
+		pf, pt := c.lookup(p, from, date), c.lookup(p, to, date)
+		if pf != nil && pt != nil {
+			return new(big.Rat).Quo(pt, pf)
+		}
+	}
+	return nil
+}
+
+// lookup finds the latest quote of base in currency on or before date
+func (c *currencyConverter) lookup(base, currency, date string) *big.Rat {
+	quotes := c.rates[[2]string{base, currency}]
+	i := sort.Search(len(quotes), func(i int) bool { return quotes[i].date > date })
+	if i == 0 {
+		return nil
+	}
+	return quotes[i-1].rate
+}
+
+// convertTransactions fills in BaseAmount for each row and returns the
+// currencies that could not be converted, which are left without one
+func convertTransactions(db *gorm.DB, base string, transactions []models.Transaction) ([]string, error) {
+	seen := map[string]bool{}
+	var currencies []string
+	for _, t := range transactions {
+		if cur := models.NormalizeCurrency(t.Currency); !seen[cur] {
+			seen[cur] = true
+			currencies = append(currencies, cur)
+		}
+	}
+
+	converter, err := newCurrencyConverter(db, base, currencies)
+	if err != nil {
+		return nil, err
+	}
+
+	missing := map[string]bool{}
+	for i := range transactions {
+		t := &transactions[i]
+		if amount, ok := converter.Convert(t.Amount, t.Currency, t.Date); ok {
+			t.BaseAmount, t.BaseCurrency = &amount, converter.base
+		} else {
+			missing[models.NormalizeCurrency(t.Currency)] = true
+		}
+	}
+
+	unconverted := make([]string, 0, len(missing))
+	for cur := range missing {
+		unconverted = append(unconverted, cur)
+	}
+	sort.Strings(unconverted)
+	return unconverted, nil
+}
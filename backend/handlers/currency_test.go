@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"math/big"
+	"testing"
+
+	"ledger-lens/backend/models"
+)
+
+func TestCurrencyConverter(t *testing.T) {
+	rat := func(s string) *big.Rat {
+		r, _ := new(big.Rat).SetString(s)
+		return r
+	}
+	c := &currencyConverter{
+		base: "TWD",
+		rates: map[[2]string][]datedRate{
+			{"EUR", "USD"}: {{"20240102", rat("1.1")}, {"20240105", rat("1.0")}},
+			{"EUR", "TWD"}: {{"20240102", rat("33")}, {"20240105", rat("34")}},
+			{"USD", "JPY"}: {{"20240102", rat("140")}},
+		},
+		pivots: []string{"EUR", "USD"},
+	}
+
+	cases := []struct {
+		amount   models.Money
+		currency string
+		date     string
+		want     models.Money
+		ok       bool
+	}{
+		{1000, "TWD", "20240101", 1000, true},
+		{10000, "EUR", "20240102", 3300, true}, // 100.00 EUR
+		{1100, "USD", "20240103", 330, true},   // 11.00 USD crossed through EUR, weekend uses the 2nd
+		{1000, "USD", "20240105", 340, true},   // 10.00 USD
+		{14000, "JPY", "20240102", 0, false},   // would need two hops
+		{100, "USD", "20240101", 0, false},     // before the first rate
+		{100, "CHF", "20240102", 0, false},     // no rate at all
+	}
+	for _, tc := range cases {
+		got, ok := c.Convert(tc.amount, tc.currency, tc.date)
+		if ok != tc.ok || got != tc.want {
+			t.Errorf("Convert(%v %s on %s) = %v, %v; want %v, %v", tc.amount, tc.currency, tc.date, got, ok, tc.want, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"net/http"
+
+	"ledger-lens/backend/database"
+	"ledger-lens/backend/models"
+	"ledger-lens/backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DuplicateCandidate pairs a held row with the ledger row it probably duplicates
+type DuplicateCandidate struct {
+	Transaction models.Transaction  `json:"transaction"`
+	Original    *models.Transaction `json:"original"` // nil if the original was removed since
+}
+
+// GetDuplicates lists probable duplicates awaiting confirmation
+func GetDuplicates(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var pending []models.Transaction
+	if err := database.DB.Where("user_id = ? AND duplicate_of_id IS NOT NULL", userID).Order("date, created_at").Find(&pending).Error; err != nil {
+		utils.LogError("GetDuplicates: DB Find failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load duplicates: " + err.Error()})
+		return
+	}
+
+	originalIDs := make([]uuid.UUID, 0, len(pending))
+	for _, t := range pending {
+		originalIDs = append(originalIDs, *t.DuplicateOfID)
+	}
+
+	var originals []models.Transaction
+	if len(originalIDs) > 0 {
+		if err := database.DB.Where("user_id = ? AND id IN ?", userID, originalIDs).Find(&originals).Error; err != nil {
+			utils.LogError("GetDuplicates: DB Find originals failed", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load duplicates: " + err.Error()})
+			return
+		}
+	}
+	byID := make(map[uuid.UUID]models.Transaction, len(originals))
+	for _, t := range originals {
+		byID[t.ID] = t
+	}
+
+	candidates := make([]DuplicateCandidate, 0, len(pending))
+	for _, t := range pending {
+		candidate := DuplicateCandidate{Transaction: t}
+		if original, ok := byID[*t.DuplicateOfID]; ok {
+			candidate.Original = &original
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"duplicates": candidates})
+}
+
+// ConfirmDuplicate agrees the held row is a duplicate and discards it
+func ConfirmDuplicate(c *gin.Context) {
+	resolveDuplicate(c, true)
+}
+
+// DismissDuplicate keeps the held row as a genuine transaction
+func DismissDuplicate(c *gin.Context) {
+	resolveDuplicate(c, false)
+}
+
+// resolveDuplicate discards or keeps a held row and records the decision by
+// fingerprint, so planImport does not hold the same row for review again
+func resolveDuplicate(c *gin.Context, isDuplicate bool) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid duplicate id"})
+		return
+	}
+
+	var held models.Transaction
+	if err := database.DB.Where("id = ? AND user_id = ? AND duplicate_of_id IS NOT NULL", id, userID).First(&held).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Duplicate not found"})
+		return
+	}
+
+	resolution := models.DuplicateResolution{UserID: userID, Fingerprint: held.Fingerprint, Resolution: models.DuplicateDismissed}
+	if isDuplicate {
+		resolution.Resolution = models.DuplicateConfirmed
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if isDuplicate {
+			if err := tx.Delete(&held).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Model(&held).Update("duplicate_of_id", nil).Error; err != nil {
+			return err
+		}
+		// Remember the decision so re-imports of the row are not held again
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "fingerprint"}},
+			DoUpdates: clause.AssignmentColumns([]string{"resolution", "resolved_at"}),
+		}).Create(&resolution).Error
+	})
+
+	if isDuplicate {
+		if err != nil {
+			utils.LogError("ConfirmDuplicate: resolve failed", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to discard duplicate: " + err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Duplicate discarded"})
+		return
+	}
+
+	if err != nil {
+		utils.LogError("DismissDuplicate: resolve failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to keep transaction: " + err.Error()})
+		return
+	}
+	held.DuplicateOfID = nil
+	c.JSON(http.StatusOK, gin.H{"message": "Transaction kept", "transaction": held})
+}
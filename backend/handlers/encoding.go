@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// Supported CSV encodings
+const (
+	EncodingUTF8    = "utf-8"
+	EncodingBig5    = "big5"
+	EncodingUTF16LE = "utf-16le"
+	EncodingUTF16BE = "utf-16be"
+)
+
+// encodingSniffSize is how much of the file is inspected when guessing the encoding
+const encodingSniffSize = 64 << 10
+
+var errUnknownEncoding = errors.New("unknown encoding")
+
+// encodingAliases maps accepted override spellings to a supported encoding
+var encodingAliases = map[string]string{
+	"utf-8":    EncodingUTF8,
+	"utf8":     EncodingUTF8,
+	"big5":     EncodingBig5,
+	"big-5":    EncodingBig5,
+	"cp950":    EncodingBig5,
+	"utf-16le": EncodingUTF16LE,
+	"utf16le":  EncodingUTF16LE,
+	"utf-16":   EncodingUTF16LE, // what Excel calls "Unicode"
+	"utf-16be": EncodingUTF16BE,
+	"utf16be":  EncodingUTF16BE,
+}
+
+// parseEncoding normalises an encoding override; empty means auto-detect
+func parseEncoding(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	if enc, ok := encodingAliases[strings.ToLower(strings.TrimSpace(s))]; ok {
+		return enc, nil
+	}
+	return "", fmt.Errorf("%w %q (expected utf-8, big5, utf-16le or utf-16be)", errUnknownEncoding, s)
+}
+
+// decodeToUTF8 wraps r so it yields UTF-8. The encoding is taken from a BOM,
+// then the override, then a heuristic over the first bytes of the file.
+// It returns the encoding that was used.
+func decodeToUTF8(r io.Reader, override string) (io.Reader, string, error) {
+	br := bufio.NewReaderSize(r, encodingSniffSize)
+	sample, err := br.Peek(encodingSniffSize)
+	if err != nil && err != io.EOF && !errors.Is(err, bufio.ErrBufferFull) {
+		return nil, "", err
+	}
+
+	enc, hasBOM := sniffBOM(sample)
+	if !hasBOM {
+		enc = override
+		if enc == "" {
+			enc = guessEncoding(sample, len(sample) < encodingSniffSize)
+		}
+	}
+
+	var decoder *encoding.Decoder
+	switch enc {
+	case EncodingUTF8:
+		// The UTF-8 BOM, if any, is stripped from the header by parseCSV
+		return br, enc, nil
+	case EncodingBig5:
+		decoder = traditionalchinese.Big5.NewDecoder()
+	case EncodingUTF16LE:
+		decoder = unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewDecoder()
+	case EncodingUTF16BE:
+		decoder = unicode.UTF16(unicode.BigEndian, unicode.UseBOM).NewDecoder()
+	default:
+		return nil, "", fmt.Errorf("%w %q", errUnknownEncoding, enc)
+	}
+	return transform.NewReader(br, decoder), enc, nil
+}
+
+// sniffBOM recognises byte order marks
+func sniffBOM(sample []byte) (string, bool) {
+	switch {
+	case bytes.HasPrefix(sample, []byte{0xEF, 0xBB, 0xBF}):
+		return EncodingUTF8, true
+	case bytes.HasPrefix(sample, []byte{0xFF, 0xFE}):
+		return EncodingUTF16LE, true
+	case bytes.HasPrefix(sample, []byte{0xFE, 0xFF}):
+		return EncodingUTF16BE, true
+	}
+	return "", false
+}
+
+// guessEncoding picks an encoding for BOM-less data. UTF-16 shows up as NUL
+// bytes in every other position for ASCII text such as digits and commas;
+// anything else that is not valid UTF-8 is assumed to be Big5, the usual
+// encoding of Taiwanese bank and Excel exports.
+func guessEncoding(sample []byte, complete bool) string {
+	if len(sample) >= 2 {
+		var evenNUL, oddNUL int
+		for i, b := range sample {
+			if b != 0 {
+				continue
+			}
+			if i%2 == 0 {
+				evenNUL++
+			} else {
+				oddNUL++
+			}
+		}
+		half := len(sample) / 2
+		switch {
+		case oddNUL > half/4 && evenNUL < oddNUL/4:
+			return EncodingUTF16LE
+		case evenNUL > half/4 && oddNUL < evenNUL/4:
+			return EncodingUTF16BE
+		}
+	}
+
+	if !complete {
+		// Don't let a multi-byte character cut at the sample boundary fail validation
+		for i := 1; i <= utf8.UTFMax && i <= len(sample); i++ {
+			if utf8.RuneStart(sample[len(sample)-i]) {
+				if !utf8.FullRune(sample[len(sample)-i:]) {
+					sample = sample[:len(sample)-i]
+				}
+				break
+			}
+		}
+	}
+	if utf8.Valid(sample) {
+		return EncodingUTF8
+	}
+	return EncodingBig5
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseCSVEncodings(t *testing.T) {
+	cases := []struct {
+		file     string
+		override string
+		want     string
+	}{
+		{"ledger_utf8.csv", "", EncodingUTF8},
+		{"ledger_utf8_bom.csv", "", EncodingUTF8},
+		{"ledger_big5.csv", "", EncodingBig5},
+		{"ledger_utf16le_bom.csv", "", EncodingUTF16LE},
+		{"ledger_utf16le.csv", "", EncodingUTF16LE},
+		{"ledger_utf16be.csv", "", EncodingUTF16BE},
+		{"ledger_big5.csv", EncodingBig5, EncodingBig5},
+		// A BOM wins over a wrong override
+		{"ledger_utf16le_bom.csv", EncodingBig5, EncodingUTF16LE},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.file+"/"+tc.override, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", tc.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			parsed, err := parseCSV(f, ParseOptions{Encoding: tc.override})
+			if err != nil {
+				t.Fatalf("Failed to parse CSV: %v", err)
+			}
+			if parsed.Encoding != tc.want {
+				t.Errorf("Expected encoding %s, got %s", tc.want, parsed.Encoding)
+			}
+			if len(parsed.Rows) != 2 {
+				t.Fatalf("Expected 2 rows, got %d: %+v", len(parsed.Rows), parsed.Report.Diagnostics)
+			}
+			if r := parsed.Rows[0]; r.Category != "午餐" || r.MainCategory != "飲食" || r.Type != "支" {
+				t.Errorf("Mojibake in first row: %q %q %q", r.Category, r.MainCategory, r.Type)
+			}
+			if r := parsed.Rows[1]; r.Amount != 52000 || r.Note != "三月薪資" {
+				t.Errorf("Unexpected second row: %v %q", r.Amount, r.Note)
+			}
+		})
+	}
+}
+
+func TestParseEncoding(t *testing.T) {
+	if enc, err := parseEncoding("CP950"); err != nil || enc != EncodingBig5 {
+		t.Errorf("Expected cp950 to alias big5, got %q %v", enc, err)
+	}
+	if _, err := parseEncoding("shift_jis"); err == nil {
+		t.Error("Expected error for unsupported encoding")
+	}
+}
@@ -0,0 +1,448 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"ledger-lens/backend/database"
+	"ledger-lens/backend/models"
+	"ledger-lens/backend/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ImportMode decides what happens to rows already in the ledger
+type ImportMode string
+
+const (
+	// ImportModeMerge upserts rows by UUID and keeps everything else
+	ImportModeMerge ImportMode = "merge"
+	// ImportModeOverwrite makes the ledger match the imported file exactly
+	ImportModeOverwrite ImportMode = "overwrite"
+)
+
+func parseImportMode(s string) (ImportMode, error) {
+	switch ImportMode(s) {
+	case "", ImportModeMerge:
+		return ImportModeMerge, nil
+	case ImportModeOverwrite:
+		return ImportModeOverwrite, nil
+	default:
+		return "", fmt.Errorf("unknown import mode %q (expected merge or overwrite)", s)
+	}
+}
+
+// ImportResult reports what an import did to the ledger
+type ImportResult struct {
+	Mode       ImportMode `json:"mode"`
+	Inserted   int        `json:"inserted"`
+	Updated    int        `json:"updated"`
+	Unchanged  int        `json:"unchanged"`
+	Deleted    int        `json:"deleted"`
+	Duplicates int        `json:"duplicates"` // probable duplicates held for review
+}
+
+// importPlan is the change set computed from the incoming rows and the current ledger.
+// It is serialized as-is when an import is previewed.
+type importPlan struct {
+	Mode      ImportMode           `json:"mode"`
+	Inserts   []models.Transaction `json:"inserts"`
+	Updates   []models.Transaction `json:"updates"` // ID is the existing row's ID
+	Deletes   []uuid.UUID          `json:"deletes"`
+	Unchanged int                  `json:"unchanged"`
+	// Duplicates are inserted as pending rows with DuplicateOfID set
+	Duplicates []models.Transaction `json:"duplicates"`
+
+	// Pre-images for previews; not needed to apply the plan
+	Replaced []models.Transaction `json:"-"` // parallel to Updates
+	Removed  []models.Transaction `json:"-"` // parallel to Deletes
+}
+
+func (p *importPlan) result() *ImportResult {
+	return &ImportResult{
+		Mode:       p.Mode,
+		Inserted:   len(p.Inserts),
+		Updated:    len(p.Updates),
+		Unchanged:  p.Unchanged,
+		Deleted:    len(p.Deletes),
+		Duplicates: len(p.Duplicates),
+	}
+}
+
+// defaultImportMaxBytes caps uploaded CSV files unless IMPORT_MAX_BYTES is set
+const defaultImportMaxBytes = 10 << 20
+
+var (
+	errInvalidCSV   = errors.New("invalid CSV")
+	errFileTooLarge = errors.New("file exceeds the import size limit")
+	// Rejected rows without a UUID cannot be told apart from rows the user
+	// removed, so an overwrite would delete their stored versions
+	errOverwriteFailedRows = errors.New("overwrite refused: some rows failed and have no UUID to match; fix them or import in merge mode")
+)
+
+// importMaxBytes returns the configured upload size limit
+func importMaxBytes() int64 {
+	if v, err := strconv.ParseInt(os.Getenv("IMPORT_MAX_BYTES"), 10, 64); err == nil && v > 0 {
+		return v
+	}
+	return defaultImportMaxBytes
+}
+
+// sizeLimitedReader fails with errFileTooLarge once more than limit bytes are read
+type sizeLimitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func newSizeLimitedReader(r io.Reader, limit int64) io.Reader {
+	return &sizeLimitedReader{r: r, remaining: limit}
+}
+
+func (l *sizeLimitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, errFileTooLarge
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, errFileTooLarge
+	}
+	return n, err
+}
+
+// ImportOptions are the caller's choices for an upload
+type ImportOptions struct {
+	Mode     ImportMode
+	Profile  string // mapping profile ID; empty to auto-detect
+	Encoding string // from parseEncoding; empty to auto-detect
+}
+
+// ImportOutcome is what an upload returns to either channel
+type ImportOutcome struct {
+	Encoding string          `json:"encoding"`
+	Profile  string          `json:"profile"`
+	Columns  []ColumnMapping `json:"columns"`
+	Report   *ImportReport   `json:"report"`
+	Result   *ImportResult   `json:"result"`
+}
+
+// importCSV is the single pipeline behind web and LINE uploads: parse with
+// diagnostics, then import the valid rows. Parse failures wrap errInvalidCSV.
+func importCSV(userID uuid.UUID, r io.Reader, opts ImportOptions) (*ImportOutcome, error) {
+	parseOpts, err := resolveParseOptions(userID, opts.Profile)
+	if err != nil {
+		return nil, err
+	}
+	parseOpts.Encoding = opts.Encoding
+
+	parsed, err := parseCSV(newSizeLimitedReader(r, importMaxBytes()), parseOpts)
+	if errors.Is(err, errFileTooLarge) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidCSV, err)
+	}
+
+	result, err := importTransactions(userID, parsed.Rows, parsed.Report, opts.Mode)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ImportOutcome{
+		Encoding: parsed.Encoding,
+		Profile:  parsed.Profile,
+		Columns:  parsed.Columns,
+		Report:   parsed.Report,
+		Result:   result,
+	}, nil
+}
+
+// importTransactions writes already-parsed rows for any channel, then checks
+// the user's budgets. The report tells an overwrite which rows were rejected.
+func importTransactions(userID uuid.UUID, rows []models.Transaction, report *ImportReport, mode ImportMode) (*ImportResult, error) {
+	var result *ImportResult
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		plan, err := planImport(tx, userID, rows, report, mode)
+		if err != nil {
+			return err
+		}
+		if err := applyImportPlan(tx, userID, plan); err != nil {
+			return err
+		}
+		result = plan.result()
+		return nil
+	})
+	if err == nil {
+		notifyBudgetAlerts(userID)
+	}
+	return result, err
+}
+
+// planImport diffs the incoming rows against the user's ledger.
+// Rows are matched on the CSV UUID. Rows without one are matched on their
+// fingerprint: in merge mode a match is held as a probable duplicate, unless
+// the user already resolved one with that fingerprint; in overwrite mode it
+// is treated as the same row. An overwrite keeps the stored
+// versions of rows the report rejected, and is refused with
+// errOverwriteFailedRows when a rejected row has no UUID to find them by.
+func planImport(tx *gorm.DB, userID uuid.UUID, rows []models.Transaction, report *ImportReport, mode ImportMode) (*importPlan, error) {
+	if mode == ImportModeOverwrite && report.FailedRows > len(report.FailedUUIDs) {
+		return nil, errOverwriteFailedRows
+	}
+
+	var existing []models.Transaction
+	if err := tx.Where("user_id = ?", userID).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	var fingerprints []string
+	if err := tx.Model(&models.DuplicateResolution{}).Where("user_id = ?", userID).Pluck("fingerprint", &fingerprints).Error; err != nil {
+		return nil, err
+	}
+	reviewed := make(map[string]bool, len(fingerprints))
+	for _, f := range fingerprints {
+		reviewed[f] = true
+	}
+
+	return diffImport(userID, existing, reviewed, rows, report, mode), nil
+}
+
+// diffImport computes planImport's change set from the stored rows and the
+// fingerprints whose duplicates the user already reviewed
+func diffImport(userID uuid.UUID, existing []models.Transaction, reviewed map[string]bool, rows []models.Transaction, report *ImportReport, mode ImportMode) *importPlan {
+
+	byUUID := make(map[string]models.Transaction)
+	byFingerprint := make(map[string][]models.Transaction) // confirmed rows, consumed as matched
+	pendingByFingerprint := make(map[string]int)
+	for _, t := range existing {
+		if t.SourceUUID != "" {
+			byUUID[t.SourceUUID] = t
+		}
+		if t.DuplicateOfID != nil {
+			pendingByFingerprint[t.Fingerprint]++
+		} else {
+			byFingerprint[t.Fingerprint] = append(byFingerprint[t.Fingerprint], t)
+		}
+	}
+
+	plan := &importPlan{Mode: mode}
+	matched := make(map[uuid.UUID]bool)
+	for _, row := range dedupeByUUID(rows) {
+		row.UserID = userID
+		row.Fingerprint = row.ComputeFingerprint()
+		row.DuplicateOfID = nil
+
+		current, ok := byUUID[row.SourceUUID]
+		if row.SourceUUID == "" {
+			current, ok = takeFingerprintMatch(byFingerprint, row.Fingerprint, matched)
+			if ok && mode == ImportModeMerge {
+				matched[current.ID] = true
+				if pendingByFingerprint[row.Fingerprint] > 0 {
+					// Already held for review by an earlier import
+					pendingByFingerprint[row.Fingerprint]--
+					plan.Unchanged++
+					continue
+				}
+				if reviewed[row.Fingerprint] {
+					// The user already discarded or kept this row once
+					plan.Unchanged++
+					continue
+				}
+				row.ID = uuid.Nil
+				duplicateOf := current.ID
+				row.DuplicateOfID = &duplicateOf
+				plan.Duplicates = append(plan.Duplicates, row)
+				continue
+			}
+		}
+		if !ok {
+			row.ID = uuid.Nil
+			plan.Inserts = append(plan.Inserts, row)
+			continue
+		}
+
+		matched[current.ID] = true
+		row.ID = current.ID
+
+		switch {
+		case sameTransaction(row, current):
+			plan.Unchanged++
+		case mode == ImportModeOverwrite || isNewer(row.LastUpdated, current.LastUpdated):
+			plan.Updates = append(plan.Updates, row)
+			plan.Replaced = append(plan.Replaced, current)
+		default:
+			plan.Unchanged++
+		}
+	}
+
+	if mode == ImportModeOverwrite {
+		for _, t := range overwriteRemovals(existing, matched, report) {
+			plan.Deletes = append(plan.Deletes, t.ID)
+			plan.Removed = append(plan.Removed, t)
+		}
+	}
+
+	return plan
+}
+
+// overwriteRemovals lists the stored rows an overwrite deletes: those the
+// file no longer has, except ones whose new version the report rejected
+func overwriteRemovals(existing []models.Transaction, matched map[uuid.UUID]bool, report *ImportReport) []models.Transaction {
+	failed := make(map[string]bool, len(report.FailedUUIDs))
+	for _, id := range report.FailedUUIDs {
+		failed[id] = true
+	}
+	var removed []models.Transaction
+	for _, t := range existing {
+		if !matched[t.ID] && (t.SourceUUID == "" || !failed[t.SourceUUID]) {
+			removed = append(removed, t)
+		}
+	}
+	return removed
+}
+
+// applyImportPlan writes a computed plan inside the caller's DB transaction.
+// Ownership and fingerprints are re-derived because a previewed plan comes
+// back from JSON without them.
+func applyImportPlan(tx *gorm.DB, userID uuid.UUID, plan *importPlan) error {
+	for _, rows := range [][]models.Transaction{plan.Inserts, plan.Updates, plan.Duplicates} {
+		for i := range rows {
+			rows[i].UserID = userID
+			rows[i].Fingerprint = rows[i].ComputeFingerprint()
+		}
+	}
+
+	if len(plan.Deletes) > 0 {
+		if err := tx.Where("user_id = ? AND id IN ?", userID, plan.Deletes).Delete(&models.Transaction{}).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, row := range plan.Updates {
+		err := tx.Model(&models.Transaction{}).
+			Where("id = ? AND user_id = ?", row.ID, userID).
+			Select("*").Omit("id", "user_id", "created_at", clause.Associations).
+			Updates(&row).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(plan.Inserts) > 0 {
+		if err := tx.CreateInBatches(&plan.Inserts, 500).Error; err != nil {
+			return err
+		}
+	}
+
+	if len(plan.Duplicates) > 0 {
+		if err := tx.CreateInBatches(&plan.Duplicates, 500).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// takeFingerprintMatch pops the first confirmed row with the fingerprint that
+// no other incoming row has claimed yet
+func takeFingerprintMatch(byFingerprint map[string][]models.Transaction, fingerprint string, matched map[uuid.UUID]bool) (models.Transaction, bool) {
+	candidates := byFingerprint[fingerprint]
+	for len(candidates) > 0 {
+		t := candidates[0]
+		candidates = candidates[1:]
+		byFingerprint[fingerprint] = candidates
+		if !matched[t.ID] {
+			return t, true
+		}
+	}
+	return models.Transaction{}, false
+}
+
+// dedupeByUUID keeps the newest version of rows sharing a UUID within one file
+func dedupeByUUID(rows []models.Transaction) []models.Transaction {
+	index := make(map[string]int)
+	out := make([]models.Transaction, 0, len(rows))
+	for _, row := range rows {
+		if row.SourceUUID == "" {
+			out = append(out, row)
+			continue
+		}
+		if i, ok := index[row.SourceUUID]; ok {
+			if isNewer(row.LastUpdated, out[i].LastUpdated) {
+				out[i] = row
+			}
+			continue
+		}
+		index[row.SourceUUID] = len(out)
+		out = append(out, row)
+	}
+	return out
+}
+
+// isNewer reports whether the incoming 上次更新 value is later than the stored one
+func isNewer(incoming, stored string) bool {
+	if incoming == stored {
+		return false
+	}
+	a, errA := utils.ParseTimestamp(incoming)
+	b, errB := utils.ParseTimestamp(stored)
+	if errA == nil && errB == nil {
+		return a.After(b)
+	}
+	if errA == nil {
+		return true
+	}
+	if errB == nil {
+		return false
+	}
+	return incoming > stored
+}
+
+// sameTransaction compares the user-visible fields of two rows
+func sameTransaction(a, b models.Transaction) bool {
+	return a.Date == b.Date &&
+		a.Category == b.Category &&
+		a.MainCategory == b.MainCategory &&
+		a.Amount == b.Amount &&
+		a.Currency == b.Currency &&
+		a.Member == b.Member &&
+		a.Account == b.Account &&
+		a.Tags == b.Tags &&
+		a.Note == b.Note &&
+		a.Type == b.Type &&
+		a.LastUpdated == b.LastUpdated &&
+		a.SourceUUID == b.SourceUUID
+}
+
+// ledgerVersion fingerprints the current state of a user's rows so a
+// previewed plan can detect that the ledger changed before it is committed
+func ledgerVersion(tx *gorm.DB, userID uuid.UUID) (string, error) {
+	var rows []struct {
+		ID        uuid.UUID
+		UpdatedAt time.Time
+	}
+	err := tx.Model(&models.Transaction{}).
+		Select("id, updated_at").
+		Where("user_id = ?", userID).
+		Order("id").
+		Find(&rows).Error
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	for _, r := range rows {
+		fmt.Fprintf(h, "%s|%d\n", r.ID, r.UpdatedAt.UnixNano())
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
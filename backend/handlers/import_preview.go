@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"ledger-lens/backend/database"
+	"ledger-lens/backend/models"
+	"ledger-lens/backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// importPreviewTTL is how long a previewed change set can be committed
+const importPreviewTTL = 30 * time.Minute
+
+var errStalePreview = errors.New("ledger changed since the preview was created")
+
+// TransactionChange shows a row before and after an import
+type TransactionChange struct {
+	Before models.Transaction `json:"before"`
+	After  models.Transaction `json:"after"`
+}
+
+// ImportDiff lists what an import would do to the ledger
+type ImportDiff struct {
+	New        []models.Transaction `json:"new"`
+	Changed    []TransactionChange  `json:"changed"`
+	Removed    []models.Transaction `json:"removed"`
+	Duplicates []models.Transaction `json:"duplicates"`
+	Unchanged  int                  `json:"unchanged"`
+}
+
+func newImportDiff(plan *importPlan) ImportDiff {
+	diff := ImportDiff{
+		New:        append([]models.Transaction{}, plan.Inserts...),
+		Changed:    make([]TransactionChange, 0, len(plan.Updates)),
+		Removed:    append([]models.Transaction{}, plan.Removed...),
+		Duplicates: append([]models.Transaction{}, plan.Duplicates...),
+		Unchanged:  plan.Unchanged,
+	}
+	for i, after := range plan.Updates {
+		diff.Changed = append(diff.Changed, TransactionChange{Before: plan.Replaced[i], After: after})
+	}
+	return diff
+}
+
+type CommitImportInput struct {
+	PreviewID string `json:"preview_id" binding:"required"`
+}
+
+// PreviewImport parses an uploaded CSV and reports what importing it would
+// change, without touching the ledger. The change set is kept so that
+// CommitImport applies exactly what was previewed.
+func PreviewImport(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	if !limitUploadBody(c) {
+		return
+	}
+
+	mode, err := parseImportMode(c.PostForm("mode"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	enc, err := parseEncoding(c.PostForm("encoding"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	parseOpts, err := resolveParseOptions(userID, c.PostForm("profile"))
+	if errors.Is(err, errUnknownProfile) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		utils.LogError("PreviewImport: resolveParseOptions failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load import profiles: " + err.Error()})
+		return
+	}
+	parseOpts.Encoding = enc
+
+	file, ok := openUploadedFile(c)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	parsed, err := parseCSV(newSizeLimitedReader(file, importMaxBytes()), parseOpts)
+	if errors.Is(err, errFileTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse CSV: " + err.Error()})
+		return
+	}
+
+	var plan *importPlan
+	var version string
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if plan, err = planImport(tx, userID, parsed.Rows, parsed.Report, mode); err != nil {
+			return err
+		}
+		version, err = ledgerVersion(tx, userID)
+		return err
+	})
+	if errors.Is(err, errOverwriteFailedRows) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "report": parsed.Report})
+		return
+	}
+	if err != nil {
+		utils.LogError("PreviewImport: planImport failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute preview: " + err.Error()})
+		return
+	}
+
+	planJSON, err := json.Marshal(plan)
+	if err != nil {
+		utils.LogError("PreviewImport: marshal plan failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store preview"})
+		return
+	}
+
+	// Drop this user's expired previews before adding a new one
+	database.DB.Where("user_id = ? AND expires_at < ?", userID, time.Now()).Delete(&models.ImportPreview{})
+
+	preview := models.ImportPreview{
+		UserID:        userID,
+		Plan:          planJSON,
+		LedgerVersion: version,
+		ExpiresAt:     time.Now().Add(importPreviewTTL),
+	}
+	if err := database.DB.Create(&preview).Error; err != nil {
+		utils.LogError("PreviewImport: DB Create failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store preview: " + err.Error()})
+		return
+	}
+
+	rows := parsed.Rows
+	if rows == nil {
+		rows = []models.Transaction{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"preview_id": preview.ID,
+		"expires_at": preview.ExpiresAt,
+		"encoding":   parsed.Encoding,
+		"profile":    parsed.Profile,
+		"columns":    parsed.Columns,
+		"rows":       rows,
+		"report":     parsed.Report,
+		"diff":       newImportDiff(plan),
+		"result":     plan.result(),
+	})
+}
+
+// CommitImport applies a previewed change set
+func CommitImport(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var input CommitImportInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	previewID, err := uuid.Parse(input.PreviewID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid preview_id"})
+		return
+	}
+
+	var preview models.ImportPreview
+	if err := database.DB.Where("id = ? AND user_id = ? AND expires_at > ?", previewID, userID, time.Now()).First(&preview).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Preview not found or expired"})
+		return
+	}
+
+	var plan importPlan
+	if err := json.Unmarshal(preview.Plan, &plan); err != nil {
+		utils.LogError("CommitImport: unmarshal plan failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read preview"})
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		version, err := ledgerVersion(tx, userID)
+		if err != nil {
+			return err
+		}
+		if version != preview.LedgerVersion {
+			return errStalePreview
+		}
+		if err := applyImportPlan(tx, userID, &plan); err != nil {
+			return err
+		}
+		return tx.Delete(&preview).Error
+	})
+	if errors.Is(err, errStalePreview) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Ledger changed since the preview; please preview again"})
+		return
+	}
+	if err != nil {
+		utils.LogError("CommitImport: applyImportPlan failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply import: " + err.Error()})
+		return
+	}
+
+	notifyBudgetAlerts(userID)
+	c.JSON(http.StatusOK, gin.H{"message": "Import applied", "result": plan.result()})
+}
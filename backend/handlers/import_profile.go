@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"ledger-lens/backend/database"
+	"ledger-lens/backend/models"
+	"ledger-lens/backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// canonicalFields are the targets a header column can be mapped to.
+// expenseAmount/incomeAmount cover statements with separate debit and credit columns.
+var canonicalFields = map[string]bool{
+	"date":          true,
+	"category":      true,
+	"mainCategory":  true,
+	"amount":        true,
+	"expenseAmount": true,
+	"incomeAmount":  true,
+	"currency":      true,
+	"member":        true,
+	"account":       true,
+	"tags":          true,
+	"note":          true,
+	"type":          true,
+	"lastUpdated":   true,
+	"uuid":          true,
+}
+
+// mappingProfile is a resolved column mapping used by parseCSV
+type mappingProfile struct {
+	ID               string            `json:"id"` // built-in name or profile UUID
+	Name             string            `json:"name"`
+	BuiltIn          bool              `json:"built_in"`
+	Columns          map[string]string `json:"columns"`
+	DateFormat       string            `json:"date_format"`
+	DecimalSeparator string            `json:"decimal_separator"`
+	SignConvention   string            `json:"sign_convention"`
+}
+
+// defaultProfileID names the mapping for the bookkeeping app export the bot was built around
+const defaultProfileID = "ledger-lens"
+
+// builtInProfiles ship with the server and are available to every user
+var builtInProfiles = []mappingProfile{
+	{
+		ID:      defaultProfileID,
+		Name:    "記帳 App 匯出 (預設)",
+		BuiltIn: true,
+		Columns: map[string]string{
+			"日期":   "date",
+			"類別":   "category",
+			"大類別":  "mainCategory",
+			"金額":   "amount",
+			"貨幣":   "currency",
+			"成員":   "member",
+			"帳戶":   "account",
+			"標籤":   "tags",
+			"備註":   "note",
+			"收支區分": "type",
+			"上次更新": "lastUpdated",
+			"UUID": "uuid",
+		},
+		DecimalSeparator: models.DecimalPoint,
+		SignConvention:   models.SignTypeColumn,
+	},
+	{
+		ID:      "tw-bank",
+		Name:    "台灣銀行帳戶明細",
+		BuiltIn: true,
+		Columns: map[string]string{
+			"交易日期": "date",
+			"摘要":   "note",
+			"支出金額": "expenseAmount",
+			"存入金額": "incomeAmount",
+			"提款":   "expenseAmount",
+			"存款":   "incomeAmount",
+			"幣別":   "currency",
+			"備註":   "note",
+			"帳號":   "account",
+		},
+		DecimalSeparator: models.DecimalPoint,
+		SignConvention:   models.SignTypeColumn,
+	},
+	{
+		ID:      "generic-en",
+		Name:    "Generic (English headers)",
+		BuiltIn: true,
+		Columns: map[string]string{
+			"date":        "date",
+			"category":    "category",
+			"amount":      "amount",
+			"currency":    "currency",
+			"account":     "account",
+			"description": "note",
+			"note":        "note",
+			"memo":        "note",
+			"tags":        "tags",
+			"member":      "member",
+			"type":        "type",
+		},
+		DecimalSeparator: models.DecimalPoint,
+		SignConvention:   models.SignNegativeExpense,
+	},
+}
+
+var errUnknownProfile = errors.New("unknown import profile")
+
+func newMappingProfile(p models.ImportProfile) mappingProfile {
+	return mappingProfile{
+		ID:               p.ID.String(),
+		Name:             p.Name,
+		Columns:          p.Columns.Data(),
+		DateFormat:       p.DateFormat,
+		DecimalSeparator: p.DecimalSeparator,
+		SignConvention:   p.SignConvention,
+	}
+}
+
+// field returns the canonical field for a header, matching case-insensitively
+func (p *mappingProfile) field(header string) string {
+	header = strings.TrimSpace(header)
+	if f, ok := p.Columns[header]; ok {
+		return f
+	}
+	for h, f := range p.Columns {
+		if strings.EqualFold(h, header) {
+			return f
+		}
+	}
+	return ""
+}
+
+// score counts recognised columns, or -1 if a required field is missing
+func (p *mappingProfile) score(header []string) int {
+	n := 0
+	present := make(map[string]bool)
+	for _, h := range header {
+		if f := p.field(h); f != "" {
+			n++
+			present[f] = true
+		}
+	}
+	if !present["date"] || !(present["amount"] || present["expenseAmount"] || present["incomeAmount"]) {
+		return -1
+	}
+	return n
+}
+
+// detectProfile picks the candidate matching the most header columns
+func detectProfile(header []string, candidates []mappingProfile) (*mappingProfile, error) {
+	var best *mappingProfile
+	bestScore := -1
+	for i := range candidates {
+		if s := candidates[i].score(header); s > bestScore {
+			best, bestScore = &candidates[i], s
+		}
+	}
+	if best == nil {
+		return nil, errors.New("no import profile matches the header row; missing date or amount column")
+	}
+	return best, nil
+}
+
+// userProfiles returns the user's own profiles followed by the built-ins,
+// so a user profile wins a detection tie
+func userProfiles(userID uuid.UUID) ([]mappingProfile, error) {
+	var stored []models.ImportProfile
+	if err := database.DB.Where("user_id = ?", userID).Order("name").Find(&stored).Error; err != nil {
+		return nil, err
+	}
+	profiles := make([]mappingProfile, 0, len(stored)+len(builtInProfiles))
+	for _, p := range stored {
+		profiles = append(profiles, newMappingProfile(p))
+	}
+	return append(profiles, builtInProfiles...), nil
+}
+
+// resolveParseOptions looks up the selected profile, or prepares auto-detection when ref is empty
+func resolveParseOptions(userID uuid.UUID, ref string) (ParseOptions, error) {
+	profiles, err := userProfiles(userID)
+	if err != nil {
+		return ParseOptions{}, err
+	}
+	if ref == "" {
+		return ParseOptions{Candidates: profiles}, nil
+	}
+	for i := range profiles {
+		if profiles[i].ID == ref {
+			return ParseOptions{Profile: &profiles[i]}, nil
+		}
+	}
+	return ParseOptions{}, fmt.Errorf("%w: %s", errUnknownProfile, ref)
+}
+
+type ImportProfileInput struct {
+	Name             string            `json:"name" binding:"required"`
+	Columns          map[string]string `json:"columns" binding:"required"`
+	DateFormat       string            `json:"date_format"`
+	DecimalSeparator string            `json:"decimal_separator"`
+	SignConvention   string            `json:"sign_convention"`
+}
+
+// validate normalises defaults and checks the mapping is usable
+func (in *ImportProfileInput) validate() error {
+	if in.DecimalSeparator == "" {
+		in.DecimalSeparator = models.DecimalPoint
+	}
+	if in.DecimalSeparator != models.DecimalPoint && in.DecimalSeparator != models.DecimalComma {
+		return fmt.Errorf("decimal_separator must be %q or %q", models.DecimalPoint, models.DecimalComma)
+	}
+	switch in.SignConvention {
+	case "":
+		in.SignConvention = models.SignTypeColumn
+	case models.SignTypeColumn, models.SignNegativeExpense, models.SignNegativeIncome:
+	default:
+		return fmt.Errorf("unknown sign_convention %q", in.SignConvention)
+	}
+	if in.DateFormat != "" {
+		if _, err := utils.DateLayout(in.DateFormat); err != nil {
+			return err
+		}
+	}
+
+	headers := make([]string, 0, len(in.Columns))
+	for header, field := range in.Columns {
+		if !canonicalFields[field] {
+			return fmt.Errorf("column %q maps to unknown field %q", header, field)
+		}
+		headers = append(headers, header)
+	}
+	probe := mappingProfile{Columns: in.Columns}
+	if probe.score(headers) < 0 {
+		return errors.New("columns must map a date and an amount (or expenseAmount/incomeAmount)")
+	}
+	return nil
+}
+
+func (in *ImportProfileInput) apply(p *models.ImportProfile) {
+	p.Name = in.Name
+	p.Columns = datatypes.NewJSONType(in.Columns)
+	p.DateFormat = in.DateFormat
+	p.DecimalSeparator = in.DecimalSeparator
+	p.SignConvention = in.SignConvention
+}
+
+// GetImportProfiles lists built-in and user-defined column mappings
+func GetImportProfiles(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	profiles, err := userProfiles(userID)
+	if err != nil {
+		utils.LogError("GetImportProfiles: DB Find failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load import profiles: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"profiles": profiles})
+}
+
+// CreateImportProfile stores a new user-defined mapping
+func CreateImportProfile(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var input ImportProfileInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := input.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	profile := models.ImportProfile{UserID: userID}
+	input.apply(&profile)
+	err := database.DB.Create(&profile).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("An import profile named %q already exists", profile.Name)})
+		return
+	}
+	if err != nil {
+		utils.LogError("CreateImportProfile: DB Create failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create import profile: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"profile": newMappingProfile(profile)})
+}
+
+// UpdateImportProfile replaces a user-defined mapping
+func UpdateImportProfile(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	profile, ok := findImportProfile(c, userID)
+	if !ok {
+		return
+	}
+
+	var input ImportProfileInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := input.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	input.apply(&profile)
+	err := database.DB.Save(&profile).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("An import profile named %q already exists", profile.Name)})
+		return
+	}
+	if err != nil {
+		utils.LogError("UpdateImportProfile: DB Save failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update import profile: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"profile": newMappingProfile(profile)})
+}
+
+// DeleteImportProfile removes a user-defined mapping
+func DeleteImportProfile(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	profile, ok := findImportProfile(c, userID)
+	if !ok {
+		return
+	}
+
+	if err := database.DB.Delete(&profile).Error; err != nil {
+		utils.LogError("DeleteImportProfile: DB Delete failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete import profile: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Import profile deleted"})
+}
+
+func findImportProfile(c *gin.Context, userID uuid.UUID) (models.ImportProfile, bool) {
+	var profile models.ImportProfile
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile id; built-in profiles cannot be modified"})
+		return profile, false
+	}
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&profile).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import profile not found"})
+		return profile, false
+	}
+	return profile, true
+}
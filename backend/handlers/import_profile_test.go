@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ledger-lens/backend/database"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// pgUniqueViolation stands in for the driver's unique-violation error; the
+// postgres dialector translates errors by their Code
+type pgUniqueViolation struct{ Code string }
+
+func (e pgUniqueViolation) Error() string {
+	return "ERROR: duplicate key value violates unique constraint (SQLSTATE " + e.Code + ")"
+}
+
+// useFailingWritesDB points database.DB at a database that runs no SQL and
+// fails every insert and update with a unique violation
+func useFailingWritesDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=unused"}), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+		TranslateError:         true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fail := func(tx *gorm.DB) { tx.AddError(pgUniqueViolation{Code: "23505"}) }
+	if err := db.Callback().Create().Before("gorm:create").Register("test:unique_violation", fail); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register("test:unique_violation", fail); err != nil {
+		t.Fatal(err)
+	}
+	prev := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = prev })
+}
+
+// callHandler runs handler for a signed-in user with a JSON body
+func callHandler(handler gin.HandlerFunc, method, body string, params ...gin.Param) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, "/", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = params
+	c.Set("user_id", uuid.New())
+	handler(c)
+	return w
+}
+
+func TestImportProfileDuplicateName(t *testing.T) {
+	useFailingWritesDB(t)
+	body := `{"name": "銀行", "columns": {"日期": "date", "金額": "amount"}}`
+
+	if w := callHandler(CreateImportProfile, http.MethodPost, body); w.Code != http.StatusConflict {
+		t.Errorf("create: status %d, want 409: %s", w.Code, w.Body)
+	}
+	id := gin.Param{Key: "id", Value: uuid.NewString()}
+	if w := callHandler(UpdateImportProfile, http.MethodPut, body, id); w.Code != http.StatusConflict {
+		t.Errorf("update: status %d, want 409: %s", w.Code, w.Body)
+	}
+}
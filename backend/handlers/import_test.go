@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ledger-lens/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestIsNewer(t *testing.T) {
+	cases := []struct {
+		incoming, stored string
+		want             bool
+	}{
+		{"2023-12-02T10:00:00Z", "2023-12-01T12:00:00Z", true},
+		{"2023-12-01T12:00:00Z", "2023-12-02T10:00:00Z", false},
+		{"2023-12-01T12:00:00Z", "2023-12-01T12:00:00Z", false},
+		{"2023/12/02 08:00:00", "2023-12-01T12:00:00Z", true},
+		{"2023-12-01T12:00:00Z", "", true},
+		{"", "2023-12-01T12:00:00Z", false},
+	}
+
+	for _, tc := range cases {
+		if got := isNewer(tc.incoming, tc.stored); got != tc.want {
+			t.Errorf("isNewer(%q, %q) = %v, want %v", tc.incoming, tc.stored, got, tc.want)
+		}
+	}
+}
+
+func TestDedupeByUUID(t *testing.T) {
+	rows := []models.Transaction{
+		{SourceUUID: "a", Note: "old", LastUpdated: "2023-12-01T00:00:00Z"},
+		{SourceUUID: "", Note: "no uuid"},
+		{SourceUUID: "a", Note: "new", LastUpdated: "2023-12-05T00:00:00Z"},
+		{SourceUUID: "", Note: "no uuid"},
+	}
+
+	out := dedupeByUUID(rows)
+	if len(out) != 3 {
+		t.Fatalf("Expected 3 rows, got %d", len(out))
+	}
+	if out[0].Note != "new" {
+		t.Errorf("Expected newest version of uuid a, got %q", out[0].Note)
+	}
+}
+
+func TestSizeLimitedReader(t *testing.T) {
+	if _, err := io.ReadAll(newSizeLimitedReader(strings.NewReader("12345"), 5)); err != nil {
+		t.Errorf("Expected file at the limit to pass, got %v", err)
+	}
+	if _, err := io.ReadAll(newSizeLimitedReader(strings.NewReader("123456"), 5)); !errors.Is(err, errFileTooLarge) {
+		t.Errorf("Expected errFileTooLarge, got %v", err)
+	}
+}
+
+// countingReader serves n bytes of CSV-like data and counts what was read
+type countingReader struct {
+	n, read int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	if r.read >= r.n {
+		return 0, io.EOF
+	}
+	p = p[:min(int64(len(p)), r.n-r.read)]
+	for i := range p {
+		p[i] = 'a'
+	}
+	r.read += int64(len(p))
+	return len(p), nil
+}
+
+func TestUploadSizeLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("IMPORT_MAX_BYTES", "1024")
+	limit := importMaxBytes() + uploadEnvelopeBytes
+
+	handlers := map[string]gin.HandlerFunc{"import": ImportTransactions, "preview": PreviewImport}
+	for name, handler := range handlers {
+		for _, knownLength := range []bool{true, false} {
+			body := &countingReader{n: 8 * limit}
+			req := httptest.NewRequest(http.MethodPost, "/api/transactions/import", io.MultiReader(
+				strings.NewReader("--b\r\nContent-Disposition: form-data; name=\"mode\"\r\n\r\nmerge\r\n"+
+					"--b\r\nContent-Disposition: form-data; name=\"file\"; filename=\"a.csv\"\r\n\r\n"),
+				body))
+			req.Header.Set("Content-Type", "multipart/form-data; boundary=b")
+			req.ContentLength = -1
+			if knownLength {
+				req.ContentLength = body.n
+			}
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+			c.Set("user_id", uuid.New())
+			handler(c)
+
+			if w.Code != http.StatusRequestEntityTooLarge {
+				t.Errorf("%s (known length %v): status %d, want 413", name, knownLength, w.Code)
+			}
+			if body.read > limit {
+				t.Errorf("%s (known length %v): read %d bytes of %d, limit %d", name, knownLength, body.read, body.n, limit)
+			}
+		}
+	}
+}
+
+func TestValidateTransactionsRoundsPostedAmounts(t *testing.T) {
+	var input TransactionInput
+	body := `{"transactions": [
+		{"date": "20240301", "type": "支", "category": "午餐", "amount": 85.5, "currency": "TWD"},
+		{"date": "20240302", "type": "支", "category": "晚餐", "amount": 120, "currency": "TWD"}
+	]}`
+	if err := json.Unmarshal([]byte(body), &input); err != nil {
+		t.Fatalf("Expected the batch to decode, got %v", err)
+	}
+
+	rows, report := validateTransactions(input.Transactions)
+	if len(rows) != 2 || rows[0].Amount != 86 {
+		t.Fatalf("Expected both rows with 85.5 rounded to 86, got %+v", rows)
+	}
+	if len(report.Diagnostics) != 1 {
+		t.Fatalf("Expected one diagnostic, got %+v", report.Diagnostics)
+	}
+	if d := report.Diagnostics[0]; d.Code != DiagRoundedAmount || d.Severity != SeverityWarning || d.Line != 1 {
+		t.Errorf("Expected a rounding warning on line 1, got %+v", d)
+	}
+}
+
+func TestOverwriteKeepsRowsThatFailedToParse(t *testing.T) {
+	csvContent := `日期,類別,金額,收支區分,UUID
+2023-12-01,Food,100,支,uuid-1
+2023-12-02,Food,1O0,支,uuid-2`
+	parsed, err := parseCSV(strings.NewReader(csvContent), ParseOptions{})
+	if err != nil {
+		t.Fatalf("Failed to parse CSV: %v", err)
+	}
+	if len(parsed.Report.FailedUUIDs) != 1 || parsed.Report.FailedUUIDs[0] != "uuid-2" {
+		t.Fatalf("Expected uuid-2 recorded as failed, got %v", parsed.Report.FailedUUIDs)
+	}
+
+	kept, gone, typo := uuid.New(), uuid.New(), uuid.New()
+	existing := []models.Transaction{
+		{ID: kept, SourceUUID: "uuid-1"},
+		{ID: typo, SourceUUID: "uuid-2"},
+		{ID: gone, SourceUUID: "uuid-3"},
+	}
+	removed := overwriteRemovals(existing, map[uuid.UUID]bool{kept: true}, parsed.Report)
+	if len(removed) != 1 || removed[0].ID != gone {
+		t.Errorf("Expected only the row missing from the file removed, got %+v", removed)
+	}
+}
+
+func TestOverwriteRefusedForFailedRowsWithoutUUID(t *testing.T) {
+	report := newImportReport()
+	report.fail("")
+	if _, err := planImport(nil, uuid.New(), nil, report, ImportModeOverwrite); !errors.Is(err, errOverwriteFailedRows) {
+		t.Errorf("Expected errOverwriteFailedRows, got %v", err)
+	}
+}
+
+func TestReimportAfterResolvingDuplicates(t *testing.T) {
+	userID := uuid.New()
+	row := models.Transaction{Date: "20240105", Category: "午餐", Amount: 120, Currency: "TWD", Type: "支"}
+	stored := func() models.Transaction {
+		s := row
+		s.ID, s.UserID = uuid.New(), userID
+		s.Fingerprint = s.ComputeFingerprint()
+		return s
+	}
+	original := stored()
+
+	plan := diffImport(userID, []models.Transaction{original}, nil, []models.Transaction{row}, newImportReport(), ImportModeMerge)
+	if len(plan.Duplicates) != 1 {
+		t.Fatalf("Expected the re-imported row held for review, got %+v", plan.result())
+	}
+
+	reviewed := map[string]bool{original.Fingerprint: true}
+	ledgers := map[string][]models.Transaction{
+		"confirmed": {original},           // the held copy was discarded
+		"dismissed": {original, stored()}, // the held copy was kept
+	}
+	for name, existing := range ledgers {
+		plan := diffImport(userID, existing, reviewed, []models.Transaction{row}, newImportReport(), ImportModeMerge)
+		if len(plan.Duplicates) != 0 || len(plan.Inserts) != 0 || plan.Unchanged != 1 {
+			t.Errorf("%s: expected the reviewed row left alone, got %+v", name, plan.result())
+		}
+	}
+}
@@ -1,21 +1,19 @@
 package handlers
 
 import (
-	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"ledger-lens/backend/database"
 	"ledger-lens/backend/models"
-	"ledger-lens/backend/storage"
 	"ledger-lens/backend/utils"
 
 	"github.com/gin-gonic/gin"
@@ -32,6 +30,37 @@ type LineTokenManager struct {
 
 var tokenManager = &LineTokenManager{}
 
+// overwriteArmTTL is how long an armed overwrite stays valid for the next upload
+const overwriteArmTTL = 10 * time.Minute
+
+// LineImportModes remembers a one-shot overwrite requested by text command,
+// keyed by LINE user ID, so the next uploaded file replaces the ledger.
+type LineImportModes struct {
+	Armed map[string]time.Time
+	Mutex sync.Mutex
+}
+
+var lineImportModes = &LineImportModes{Armed: make(map[string]time.Time)}
+
+// Arm marks the user's next upload as an overwrite
+func (m *LineImportModes) Arm(lineUserID string) {
+	m.Mutex.Lock()
+	defer m.Mutex.Unlock()
+	m.Armed[lineUserID] = time.Now().Add(overwriteArmTTL)
+}
+
+// Take returns the mode for this upload and clears any armed overwrite
+func (m *LineImportModes) Take(lineUserID string) ImportMode {
+	m.Mutex.Lock()
+	defer m.Mutex.Unlock()
+	expiresAt, ok := m.Armed[lineUserID]
+	delete(m.Armed, lineUserID)
+	if ok && time.Now().Before(expiresAt) {
+		return ImportModeOverwrite
+	}
+	return ImportModeMerge
+}
+
 // GetToken returns a valid channel access token, refreshing if necessary
 func (m *LineTokenManager) GetToken() (string, error) {
 	m.Mutex.Lock()
@@ -81,6 +110,20 @@ func (m *LineTokenManager) GetToken() (string, error) {
 	return m.Token, nil
 }
 
+// pushLineText sends an unsolicited text message to a bound LINE user
+func pushLineText(lineUserID, text string) error {
+	channelToken, err := tokenManager.GetToken()
+	if err != nil {
+		return err
+	}
+	bot, err := linebot.New(os.Getenv("LINE_CHANNEL_SECRET"), channelToken)
+	if err != nil {
+		return err
+	}
+	_, err = bot.PushMessage(lineUserID, linebot.NewTextMessage(text)).Do()
+	return err
+}
+
 // BindLineAccountRequest structure
 type BindLineAccountRequest struct {
 	IDToken string `json:"id_token" binding:"required"`
@@ -154,6 +197,14 @@ func BindLineAccount(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Line account bound successfully", "line_user_name": tokenData.Name})
 }
 
+const lineHelpText = "請上傳 CSV 檔案以更新帳本。\n" +
+	"預設以 UUID 合併：新增新紀錄、以「上次更新」較新的版本更新既有紀錄，不會刪除資料。\n" +
+	"若要以檔案完全取代現有帳本，請先傳送「覆蓋匯入」再上傳檔案。\n" +
+	"也可以直接輸入「午餐 120」、「計程車 350 信用卡」或「+薪水 50000」記帳，傳送「取消」可復原上一筆。\n" +
+	"查詢：「本月支出」、「上週餐飲」、「今年收支」、「預算」、「最近10筆」，傳送「報表」或「上月報表」可查看圖表。"
+
+const lineBindText = "尚未綁定帳號。請點擊以下連結進行綁定：\nhttps://www.hung.services/ledger-lens/line-bind"
+
 // LineWebhook handles Line Bot events
 func LineWebhook(c *gin.Context) {
 	channelSecret := os.Getenv("LINE_CHANNEL_SECRET")
@@ -195,165 +246,232 @@ func LineWebhook(c *gin.Context) {
 	}
 
 	for _, event := range events {
-		if event.Type == linebot.EventTypeMessage {
+		switch event.Type {
+		case linebot.EventTypeMessage:
 			switch message := event.Message.(type) {
 			case *linebot.FileMessage:
 				handleFileMessage(bot, event.Source.UserID, message, event.ReplyToken)
 			case *linebot.TextMessage:
-				// Optional: Handle text commands or help
-				if message.Text == "help" || message.Text == "說明" {
-					bot.ReplyMessage(event.ReplyToken, linebot.NewTextMessage("請上傳 CSV 檔案以更新帳本。上傳後將會覆蓋現有資料。")).Do()
-				}
+				handleTextMessage(bot, event.Source.UserID, message.Text, event.ReplyToken)
 			}
+		case linebot.EventTypePostback:
+			handlePostback(bot, event.Source.UserID, event.Postback.Data, event.ReplyToken)
 		}
 	}
 
 	c.Status(http.StatusOK)
 }
 
-func handleFileMessage(bot *linebot.Client, lineUserID string, message *linebot.FileMessage, replyToken string) {
-	// 1. Check if user exists
+// findLineUser returns the user bound to a LINE account, replying with the
+// binding link if there is none
+func findLineUser(bot *linebot.Client, lineUserID, replyToken string) (models.User, bool) {
 	var user models.User
 	if err := database.DB.First(&user, "line_user_id = ?", lineUserID).Error; err != nil {
-		bot.ReplyMessage(replyToken, linebot.NewTextMessage("尚未綁定帳號。請點擊以下連結進行綁定：\nhttps://www.hung.services/ledger-lens/line-bind")).Do()
-		return
+		bot.ReplyMessage(replyToken, linebot.NewTextMessage(lineBindText)).Do()
+		return user, false
 	}
+	return user, true
+}
 
-	// 2. Download file
-	content, err := bot.GetMessageContent(message.ID).Do()
-	if err != nil {
-		utils.LogError("handleFileMessage: GetMessageContent failed", err)
-		bot.ReplyMessage(replyToken, linebot.NewTextMessage("讀取檔案失敗: "+err.Error())).Do()
+// handleTextMessage answers commands and queries and books quick entries;
+// other text is ignored
+func handleTextMessage(bot *linebot.Client, lineUserID, text, replyToken string) {
+	text = strings.TrimSpace(text)
+	switch text {
+	case "help", "說明":
+		bot.ReplyMessage(replyToken, linebot.NewTextMessage(lineHelpText)).Do()
+		return
+	case "覆蓋匯入":
+		lineImportModes.Arm(lineUserID)
+		bot.ReplyMessage(replyToken, linebot.NewTextMessage("已切換為覆蓋模式：10 分鐘內上傳的下一個 CSV 檔案將取代現有帳本。")).Do()
 		return
 	}
-	defer content.Content.Close()
 
-	// 3. Parse CSV
-	transactions, err := parseCSV(content.Content)
-	if err != nil {
-		utils.LogError("handleFileMessage: parseCSV failed", err)
-		bot.ReplyMessage(replyToken, linebot.NewTextMessage("CSV 解析失敗: "+err.Error())).Do()
+	if q, ok := parseLineQuery(text); ok && replyLineQuery(bot, lineUserID, replyToken, q) {
 		return
 	}
 
-	// 4. Save to file
-	filePath, err := storage.SaveTransactionFile(user.ID.String(), transactions)
-	if err != nil {
-		utils.LogError("handleFileMessage: SaveTransactionFile failed", err)
-		bot.ReplyMessage(replyToken, linebot.NewTextMessage("檔案儲存失敗: "+err.Error())).Do()
+	entry, isEntry := parseQuickEntry(text)
+	if !isEntry && !quickEntryUndoCommands[text] {
 		return
 	}
+	user, ok := findLineUser(bot, lineUserID, replyToken)
+	if !ok {
+		return
+	}
+	var reply linebot.SendingMessage
+	if isEntry {
+		reply = saveQuickEntry(user, entry)
+	} else {
+		reply = linebot.NewTextMessage(undoQuickEntry(user))
+	}
+	bot.ReplyMessage(replyToken, reply).Do()
+}
 
-	// 5. Update database with file path
-	var userTransaction models.UserTransaction
-	result := database.DB.Where("user_id = ?", user.ID).First(&userTransaction)
-	if result.Error != nil {
-		// Create
-		userTransaction = models.UserTransaction{
-			UserID:   user.ID,
-			FilePath: filePath,
+// replyLineQuery answers a parsed query and reports whether the text was
+// one. Chat such as 今天好累 also starts with a period word, so a summary
+// naming a category only counts when the bound user has that category;
+// otherwise the text is left to the other handlers.
+func replyLineQuery(bot *linebot.Client, lineUserID, replyToken string, q lineQuery) bool {
+	var user models.User
+	if q.namesCategory() {
+		if err := database.DB.First(&user, "line_user_id = ?", lineUserID).Error; err != nil {
+			return false
+		}
+		usage, err := loadCategoryUsage(user.ID)
+		if err != nil {
+			utils.LogError("replyLineQuery: loadCategoryUsage failed", err)
+			bot.ReplyMessage(replyToken, linebot.NewTextMessage("查詢失敗，請稍後再試。")).Do()
+			return true
 		}
-		if err := database.DB.Create(&userTransaction).Error; err != nil {
-			utils.LogError("handleFileMessage: DB Create failed", err)
-			bot.ReplyMessage(replyToken, linebot.NewTextMessage("儲存失敗: "+err.Error())).Do()
-			return
+		name, ok := resolveQueryCategory(q.Subject, usage)
+		if !ok {
+			return false
 		}
+		q.Subject = name
 	} else {
-		// Update
-		userTransaction.FilePath = filePath
-		if err := database.DB.Save(&userTransaction).Error; err != nil {
-			utils.LogError("handleFileMessage: DB Save failed", err)
-			bot.ReplyMessage(replyToken, linebot.NewTextMessage("更新失敗: "+err.Error())).Do()
-			return
+		var ok bool
+		if user, ok = findLineUser(bot, lineUserID, replyToken); !ok {
+			return true
 		}
 	}
 
-	bot.ReplyMessage(replyToken, linebot.NewTextMessage(fmt.Sprintf("已成功更新 %d 筆交易紀錄。", len(transactions)))).Do()
+	if q.Kind != lineQueryReport {
+		bot.ReplyMessage(replyToken, linebot.NewTextMessage(answerLineQuery(user, q))).Do()
+		return true
+	}
+	card, err := reportCard(user, q)
+	if err != nil {
+		utils.LogError("replyLineQuery: reportCard failed", err)
+		bot.ReplyMessage(replyToken, linebot.NewTextMessage("查詢失敗，請稍後再試。")).Do()
+		return true
+	}
+	bot.ReplyMessage(replyToken, card).Do()
+	return true
 }
 
-func parseCSV(r io.Reader) ([]map[string]interface{}, error) {
-	reader := csv.NewReader(r)
-	reader.LazyQuotes = true
+// handlePostback acts on button presses; unknown actions are ignored
+func handlePostback(bot *linebot.Client, lineUserID, data, replyToken string) {
+	values, err := url.ParseQuery(data)
+	if err != nil || values.Get("action") != postbackQuickCategory {
+		return
+	}
+	user, ok := findLineUser(bot, lineUserID, replyToken)
+	if !ok {
+		return
+	}
+	bot.ReplyMessage(replyToken, pickQuickCategory(user, values.Get("id"), values.Get("category"))).Do()
+}
+
+func handleFileMessage(bot *linebot.Client, lineUserID string, message *linebot.FileMessage, replyToken string) {
+	// 1. Check if user exists
+	user, ok := findLineUser(bot, lineUserID, replyToken)
+	if !ok {
+		return
+	}
+
+	// 2. Check size before downloading
+	if message.FileSize > int(importMaxBytes()) {
+		bot.ReplyMessage(replyToken, linebot.NewTextMessage(fmt.Sprintf("檔案過大，上限為 %d MB。", importMaxBytes()>>20))).Do()
+		return
+	}
 
-	// Read Header
-	header, err := reader.Read()
+	// 3. Download file
+	content, err := bot.GetMessageContent(message.ID).Do()
 	if err != nil {
-		return nil, err
+		utils.LogError("handleFileMessage: GetMessageContent failed", err)
+		bot.ReplyMessage(replyToken, linebot.NewTextMessage("讀取檔案失敗: "+err.Error())).Do()
+		return
+	}
+	defer content.Content.Close()
+
+	// 4. Parse and import through the shared pipeline
+	mode := lineImportModes.Take(lineUserID)
+	outcome, err := importCSV(user.ID, content.Content, ImportOptions{Mode: mode})
+	switch {
+	case errors.Is(err, errFileTooLarge):
+		bot.ReplyMessage(replyToken, linebot.NewTextMessage(fmt.Sprintf("檔案過大，上限為 %d MB。", importMaxBytes()>>20))).Do()
+		return
+	case errors.Is(err, errOverwriteFailedRows):
+		bot.ReplyMessage(replyToken, linebot.NewTextMessage("部分資料列無法解析且沒有 UUID，為避免誤刪紀錄已取消覆蓋匯入。請修正檔案，或直接上傳以合併匯入。")).Do()
+		return
+	case errors.Is(err, errInvalidCSV):
+		utils.LogError("handleFileMessage: parseCSV failed", err)
+		bot.ReplyMessage(replyToken, linebot.NewTextMessage("CSV 解析失敗: "+err.Error())).Do()
+		return
+	case err != nil:
+		utils.LogError("handleFileMessage: importCSV failed", err)
+		bot.ReplyMessage(replyToken, linebot.NewTextMessage("儲存失敗: "+err.Error())).Do()
+		return
 	}
 
-	// Remove BOM if present
-	if len(header) > 0 {
-		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	// 5. Reply with the result and this month's report card
+	messages := []linebot.SendingMessage{linebot.NewTextMessage(formatImportReport(outcome.Report) + "\n" + formatImportResult(outcome.Result))}
+	if card, err := reportCard(user, lineQuery{Kind: lineQueryReport, Label: "本月", Window: "this_month"}); err != nil {
+		utils.LogError("handleFileMessage: reportCard failed", err)
+	} else {
+		messages = append(messages, card)
 	}
+	bot.ReplyMessage(replyToken, messages...).Do()
+}
+
+// maxReportedFailures caps how many failed rows are listed in a LINE reply
+const maxReportedFailures = 3
+
+// diagnosticLabels translates diagnostic codes for LINE replies
+var diagnosticLabels = map[string]string{
+	DiagColumnCount:   "欄位數不符",
+	DiagMissingValue:  "缺少%s",
+	DiagInvalidAmount: "金額無法解析",
+	DiagInvalidDate:   "日期無法解析",
+	DiagAmbiguousDate: "日期格式不明確",
+}
+
+var fieldLabels = map[string]string{
+	"date":   "日期",
+	"amount": "金額",
+}
 
-	var transactions []map[string]interface{}
+// formatImportReport summarises parse diagnostics, e.g.
+// 已匯入 120 筆，3 筆失敗：第 14 行金額無法解析…
+func formatImportReport(report *ImportReport) string {
+	if report.FailedRows == 0 {
+		return fmt.Sprintf("已匯入 %d 筆。", report.ValidRows)
+	}
 
-	for {
-		record, err := reader.Read()
-		if err == io.EOF {
+	errs := report.Errors()
+	parts := make([]string, 0, maxReportedFailures)
+	for i, d := range errs {
+		if i == maxReportedFailures {
 			break
 		}
-		if err != nil {
-			return nil, err
+		label := diagnosticLabels[d.Code]
+		if strings.Contains(label, "%s") {
+			label = fmt.Sprintf(label, fieldLabels[d.Field])
 		}
-
-		if len(record) != len(header) {
-			continue // skip invalid lines
+		if label == "" {
+			label = d.Reason
 		}
-
-		row := make(map[string]interface{})
-		for i, h := range header {
-			// Map Chinese headers to English keys
-			key := mapHeader(h)
-			if key != "" {
-				if key == "amount" {
-					// Remove commas and convert to float
-					amountStr := strings.ReplaceAll(record[i], ",", "")
-					if val, err := strconv.ParseFloat(amountStr, 64); err == nil {
-						row[key] = val
-					} else {
-						// Fallback: log warning and store as 0 to avoid frontend NaN
-						utils.LogRequest("CSV Parse Warning", []byte(fmt.Sprintf("Failed to parse amount: %s", record[i])))
-						row[key] = 0.0
-					}
-				} else {
-					row[key] = record[i]
-				}
-			}
-		}
-		transactions = append(transactions, row)
+		parts = append(parts, fmt.Sprintf("第 %d 行%s", d.Line, label))
 	}
 
-	return transactions, nil
+	text := fmt.Sprintf("已匯入 %d 筆，%d 筆失敗：%s", report.ValidRows, report.FailedRows, strings.Join(parts, "、"))
+	if len(errs) > maxReportedFailures {
+		text += "…"
+	}
+	return text
 }
 
-func mapHeader(h string) string {
-	switch h {
-	case "日期":
-		return "date"
-	case "類別":
-		return "category"
-	case "大類別":
-		return "mainCategory"
-	case "金額":
-		return "amount"
-	case "貨幣":
-		return "currency"
-	case "成員":
-		return "member"
-	case "帳戶":
-		return "account"
-	case "標籤":
-		return "tags"
-	case "備註":
-		return "note"
-	case "收支區分":
-		return "type"
-	case "上次更新":
-		return "lastUpdated"
-	case "UUID":
-		return "uuid"
-	default:
-		return ""
+// formatImportResult renders an import summary for LINE replies
+func formatImportResult(result *ImportResult) string {
+	title := "合併匯入完成"
+	if result.Mode == ImportModeOverwrite {
+		title = "覆蓋匯入完成"
+	}
+	text := fmt.Sprintf("%s：新增 %d 筆、更新 %d 筆、未變更 %d 筆、刪除 %d 筆。",
+		title, result.Inserted, result.Updated, result.Unchanged, result.Deleted)
+	if result.Duplicates > 0 {
+		text += fmt.Sprintf("\n另有 %d 筆疑似重複的紀錄已暫存，請至網頁確認或忽略。", result.Duplicates)
 	}
+	return text
 }
@@ -0,0 +1,488 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ledger-lens/backend/database"
+	"ledger-lens/backend/models"
+	"ledger-lens/backend/utils"
+
+	"github.com/google/uuid"
+	"github.com/line/line-bot-sdk-go/v8/linebot"
+)
+
+// quickEntryUndoWindow is how long a LINE entry can be taken back with 取消
+const quickEntryUndoWindow = 30 * time.Minute
+
+// Category choices offered as quick-reply buttons
+const (
+	pendingEntryTTL       = 10 * time.Minute
+	maxCategoryChoices    = 13 // LINE's quick-reply limit
+	maxQuickReplyLabel    = 20
+	maxPostbackData       = 300
+	postbackQuickCategory = "quick_category"
+)
+
+var (
+	quickAmountPattern = regexp.MustCompile(`^[$]?\d[\d,]*(\.\d+)?$`)
+	// 午餐120, typed without a space
+	quickGluedPattern = regexp.MustCompile(`^([^\d\s$+-][^\d\s$]*)([$]?\d[\d,]*(?:\.\d+)?)$`)
+)
+
+// quickEntryUndoCommands take back the last LINE entry
+var quickEntryUndoCommands = map[string]bool{"取消": true, "復原": true, "undo": true}
+
+// quickEntry is a transaction typed as a LINE message
+type quickEntry struct {
+	Type     string // 支 or 收; empty when not given with - or +
+	Category string
+	Amount   string // as typed
+	Currency string // empty for the user's base currency
+	Account  string
+	Note     string
+	Date     string // YYYYMMDD; empty for today
+	Exact    bool   // Category was picked from the buttons and is not matched again
+}
+
+// pendingEntry is a quick entry waiting for the user to pick its category
+type pendingEntry struct {
+	ID        string
+	Entry     quickEntry
+	ExpiresAt time.Time
+}
+
+// PendingQuickEntries holds the latest entry each LINE user was asked to
+// categorise; a newer entry replaces it and its buttons stop working
+type PendingQuickEntries struct {
+	Entries map[string]pendingEntry
+	Mutex   sync.Mutex
+}
+
+var pendingQuickEntries = &PendingQuickEntries{Entries: make(map[string]pendingEntry)}
+
+// Hold keeps an entry until a category is picked and returns the ID the
+// buttons carry
+func (p *PendingQuickEntries) Hold(lineUserID string, e quickEntry) string {
+	p.Mutex.Lock()
+	defer p.Mutex.Unlock()
+	id := uuid.NewString()[:8]
+	p.Entries[lineUserID] = pendingEntry{ID: id, Entry: e, ExpiresAt: time.Now().Add(pendingEntryTTL)}
+	return id
+}
+
+// Take returns the held entry for a button press, once
+func (p *PendingQuickEntries) Take(lineUserID, id string) (quickEntry, bool) {
+	p.Mutex.Lock()
+	defer p.Mutex.Unlock()
+	pending, ok := p.Entries[lineUserID]
+	if !ok || pending.ID != id {
+		return quickEntry{}, false
+	}
+	delete(p.Entries, lineUserID)
+	return pending.Entry, time.Now().Before(pending.ExpiresAt)
+}
+
+// parseQuickEntry reads "[+|-]category amount [currency] [account] [note…]",
+// e.g. 午餐 120, 計程車 350 信用卡 or +薪水 50000. The amount may also come
+// first. Text without an amount is not an entry.
+func parseQuickEntry(text string) (quickEntry, bool) {
+	var e quickEntry
+	fields := strings.Fields(utils.HalfWidth(text))
+	if len(fields) == 0 {
+		return e, false
+	}
+	switch {
+	case strings.HasPrefix(fields[0], "+"):
+		e.Type = "收"
+	case strings.HasPrefix(fields[0], "-"):
+		e.Type = "支"
+	}
+	if e.Type != "" {
+		if fields[0] = fields[0][1:]; fields[0] == "" {
+			fields = fields[1:]
+		}
+	}
+	if len(fields) > 0 {
+		if m := quickGluedPattern.FindStringSubmatch(fields[0]); m != nil {
+			fields = append([]string{m[1], m[2]}, fields[1:]...)
+		}
+	}
+
+	var rest []string
+	for i := 0; i < len(fields); i++ {
+		if e.Amount == "" && quickAmountPattern.MatchString(fields[i]) {
+			e.Amount = strings.TrimPrefix(fields[i], "$")
+			if i+1 < len(fields) && isQuickCurrency(fields[i+1]) {
+				e.Currency = models.NormalizeCurrency(fields[i+1])
+				i++
+			}
+			continue
+		}
+		rest = append(rest, fields[i])
+	}
+	if e.Amount == "" || len(rest) == 0 {
+		return e, false
+	}
+	e.Category = rest[0]
+	if len(rest) > 1 {
+		e.Account = rest[1]
+	}
+	if len(rest) > 2 {
+		e.Note = strings.Join(rest[2:], " ")
+	}
+	return e, true
+}
+
+// isQuickCurrency accepts upper-case ISO codes and known aliases such as
+// 美元, so an account named atm is not taken for a currency
+func isQuickCurrency(s string) bool {
+	return currencyCodePattern.MatchString(s) || models.NormalizeCurrency(s) != strings.ToUpper(s)
+}
+
+// categoryUsage is a category with the main category, type and account it
+// is most often booked with
+type categoryUsage struct {
+	Category     string
+	MainCategory string
+	Type         string
+	Account      string
+	Count        int
+}
+
+// loadCategoryUsage returns the user's categories, most used first
+func loadCategoryUsage(userID uuid.UUID) ([]categoryUsage, error) {
+	var rows []categoryUsage
+	if err := database.DB.Model(&models.Transaction{}).Scopes(ledgerScope(userID)).
+		Select("category, main_category, type, account, COUNT(*) AS count").
+		Where("category <> ''").
+		Group("category, main_category, type, account").
+		Order("count DESC, category").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	totals := map[string]int{}
+	for _, r := range rows {
+		totals[r.Category] += r.Count
+	}
+	var usage []categoryUsage
+	seen := map[string]bool{}
+	for _, r := range rows {
+		if !seen[r.Category] {
+			seen[r.Category] = true
+			r.Count = totals[r.Category]
+			usage = append(usage, r)
+		}
+	}
+	sort.SliceStable(usage, func(i, j int) bool { return usage[i].Count > usage[j].Count })
+	return usage, nil
+}
+
+// fuzzyMatch returns the names that match input in the first tier that has
+// any: equal ignoring case and width, containing or contained in input, then
+// one edit away for inputs of three or more characters, so 晚餐 is not taken
+// for 午餐. Order within a tier follows names.
+func fuzzyMatch(input string, names []string) []string {
+	fold := func(s string) string { return strings.ToLower(utils.HalfWidth(strings.TrimSpace(s))) }
+	in := fold(input)
+	if in == "" {
+		return nil
+	}
+	tiers := []func(name string) bool{
+		func(name string) bool { return name == in },
+		func(name string) bool { return strings.Contains(name, in) || strings.Contains(in, name) },
+		func(name string) bool { return len([]rune(in)) > 2 && editDistance(name, in) == 1 },
+	}
+	for _, match := range tiers {
+		var out []string
+		for _, name := range names {
+			if n := fold(name); n != "" && match(n) {
+				out = append(out, name)
+			}
+		}
+		if len(out) > 0 {
+			return out
+		}
+	}
+	return nil
+}
+
+// editDistance is the Levenshtein distance in runes
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
+
+// matchCategory returns the known categories input may mean, most likely
+// first. A main category name matches every category under it.
+func matchCategory(input string, usage []categoryUsage) []categoryUsage {
+	byName := map[string]categoryUsage{}
+	names := make([]string, 0, len(usage))
+	for _, u := range usage {
+		byName[u.Category] = u
+		names = append(names, u.Category)
+	}
+
+	matched := fuzzyMatch(input, names)
+	if len(matched) != 1 || !strings.EqualFold(matched[0], input) {
+		var under []string
+		for _, u := range usage {
+			if u.MainCategory != "" && strings.EqualFold(u.MainCategory, input) {
+				under = append(under, u.Category)
+			}
+		}
+		if len(under) > 0 {
+			matched = under
+		}
+	}
+
+	out := make([]categoryUsage, 0, len(matched))
+	for _, name := range matched {
+		out = append(out, byName[name])
+	}
+	return out
+}
+
+// quickTransaction turns an entry into a transaction using what the user
+// usually books the category with. The second result is false for a new category.
+func quickTransaction(e quickEntry, usage []categoryUsage, settings models.UserSettings) (models.Transaction, bool, error) {
+	t := models.Transaction{
+		UserID:   settings.UserID,
+		Date:     e.Date,
+		Category: e.Category,
+		Type:     e.Type,
+		Note:     e.Note,
+		Currency: e.Currency,
+		Origin:   models.OriginLine,
+	}
+	if t.Date == "" {
+		t.Date = settings.Now().Format(utils.StoredDateLayout)
+	}
+	if t.Currency == "" {
+		t.Currency = settings.BaseCurrency
+	}
+	amount, err := models.ParseMoney(e.Amount, t.Currency, models.DecimalPoint)
+	if errors.Is(err, models.ErrPrecisionLoss) {
+		return t, false, fmt.Errorf("金額 %s 超過 %s 的小數位數", e.Amount, t.Currency)
+	}
+	if err != nil {
+		return t, false, fmt.Errorf("金額 %s 無法解析", e.Amount)
+	}
+	t.Amount = amount
+
+	matches := matchCategory(e.Category, usage)
+	if e.Exact {
+		matches = nil
+		for _, u := range usage {
+			if u.Category == e.Category {
+				matches = append(matches, u)
+			}
+		}
+	}
+	known := false
+	if len(matches) > 0 {
+		best := matches[0]
+		known = true
+		t.Category, t.MainCategory, t.Account = best.Category, best.MainCategory, best.Account
+		if t.Type == "" {
+			t.Type = best.Type
+		}
+	}
+	if t.Type == "" || t.Type == "轉" {
+		t.Type = "支"
+	}
+
+	if e.Account != "" {
+		var accounts []string
+		seen := map[string]bool{}
+		for _, u := range usage {
+			if u.Account != "" && !seen[u.Account] {
+				seen[u.Account] = true
+				accounts = append(accounts, u.Account)
+			}
+		}
+		t.Account = e.Account
+		if matched := fuzzyMatch(e.Account, accounts); len(matched) > 0 {
+			t.Account = matched[0]
+		}
+	}
+	return t, known, nil
+}
+
+// categoryChoices lists the categories to offer when an entry's category
+// is ambiguous or unknown: every match, or else the most used categories
+// followed by the typed name as a new one, with unknown set. Without any
+// history only the typed name is offered, so chat that merely looks like an
+// entry is never booked unasked. It is empty when the category is clear.
+func categoryChoices(e quickEntry, usage []categoryUsage) (choices []string, unknown bool) {
+	if e.Exact {
+		return nil, false
+	}
+	matches := matchCategory(e.Category, usage)
+	if len(matches) == 1 {
+		return nil, false
+	}
+	if len(matches) > 1 {
+		for _, u := range matches[:min(len(matches), maxCategoryChoices)] {
+			choices = append(choices, u.Category)
+		}
+		return choices, false
+	}
+	for _, u := range usage[:min(len(usage), maxCategoryChoices-1)] {
+		choices = append(choices, u.Category)
+	}
+	return append(choices, e.Category), true
+}
+
+// categoryPrompt asks which category an entry belongs to, with a
+// quick-reply button per choice carrying the pending entry's ID
+func categoryPrompt(t models.Transaction, typed string, choices []string, unknown bool, id string) linebot.SendingMessage {
+	entry := fmt.Sprintf("%s %s %s", typed, formatLineAmount(t.Amount, t.Currency), t.Currency)
+	text := fmt.Sprintf("「%s」符合多個類別，請選擇：", entry)
+	if unknown {
+		text = fmt.Sprintf("找不到「%s」的類別，請選擇常用類別或新增：", entry)
+		if len(choices) == 1 {
+			text = fmt.Sprintf("找不到「%s」的類別，要新增嗎？", entry)
+		}
+	}
+
+	var buttons []*linebot.QuickReplyButton
+	for i, c := range choices {
+		label := c
+		if unknown && i == len(choices)-1 {
+			label = "新增「" + c + "」"
+		}
+		data := url.Values{"action": {postbackQuickCategory}, "id": {id}, "category": {c}}.Encode()
+		if len(data) > maxPostbackData {
+			continue
+		}
+		action := linebot.NewPostbackAction(truncateRunes(label, maxQuickReplyLabel), data, "", c, "", "")
+		buttons = append(buttons, linebot.NewQuickReplyButton("", action))
+	}
+	return linebot.NewTextMessage(text).WithQuickReplies(linebot.NewQuickReplyItems(buttons...))
+}
+
+var typeLabels = map[string]string{"支": "支出", "收": "收入", "轉": "轉帳"}
+
+// formatQuickEntry confirms a saved entry, e.g.
+// 已記帳：午餐 120 TWD
+func formatQuickEntry(t models.Transaction, known bool) string {
+	lines := []string{fmt.Sprintf("已記帳：%s %s %s", t.Category, formatLineAmount(t.Amount, t.Currency), t.Currency)}
+	details := []string{typeLabels[t.Type]}
+	if t.MainCategory != "" {
+		details = append(details, t.MainCategory)
+	}
+	if t.Account != "" {
+		details = append(details, t.Account)
+	}
+	details = append(details, formatLineDate(t.Date))
+	lines = append(lines, strings.Join(details, "｜"))
+	if !known {
+		lines = append(lines, "（新類別）")
+	}
+	lines = append(lines, "傳送「取消」可復原。")
+	return strings.Join(lines, "\n")
+}
+
+// formatLineDate renders YYYYMMDD as 2024/03/06
+func formatLineDate(date string) string {
+	d, err := time.Parse(utils.StoredDateLayout, date)
+	if err != nil {
+		return date
+	}
+	return d.Format("2006/01/02")
+}
+
+// formatLineShortDate renders YYYYMMDD without the year, as 03/06. Dates
+// that are not YYYYMMDD, such as unparseable legacy ones, are returned as is.
+func formatLineShortDate(date string) string {
+	d, err := time.Parse(utils.StoredDateLayout, date)
+	if err != nil {
+		return date
+	}
+	return d.Format("01/02")
+}
+
+// saveQuickEntry books a parsed entry for a bound user and returns the
+// reply. An ambiguous or unknown category is held and answered with
+// category buttons instead; the chosen one comes back as a postback.
+func saveQuickEntry(user models.User, e quickEntry) linebot.SendingMessage {
+	settings, err := loadUserSettings(user.ID)
+	if err != nil {
+		utils.LogError("saveQuickEntry: loadUserSettings failed", err)
+		return linebot.NewTextMessage("記帳失敗，請稍後再試。")
+	}
+	usage, err := loadCategoryUsage(user.ID)
+	if err != nil {
+		utils.LogError("saveQuickEntry: loadCategoryUsage failed", err)
+		return linebot.NewTextMessage("記帳失敗，請稍後再試。")
+	}
+
+	t, known, err := quickTransaction(e, usage, settings)
+	if err != nil {
+		return linebot.NewTextMessage(err.Error())
+	}
+	if choices, unknown := categoryChoices(e, usage); len(choices) > 0 {
+		e.Date = t.Date // book on the day it was typed
+		id := pendingQuickEntries.Hold(user.LineUserID, e)
+		return categoryPrompt(t, e.Category, choices, unknown, id)
+	}
+	if errs := prepareEntry(&t, settings.Now(), nil); len(errs) > 0 {
+		return linebot.NewTextMessage("無法記帳：" + errs[0].Reason)
+	}
+	if err := database.DB.Create(&t).Error; err != nil {
+		utils.LogError("saveQuickEntry: DB Create failed", err)
+		return linebot.NewTextMessage("記帳失敗，請稍後再試。")
+	}
+
+	notifyBudgetAlerts(user.ID)
+	return linebot.NewTextMessage(formatQuickEntry(t, known))
+}
+
+// pickQuickCategory finishes a held entry with the category from a button
+func pickQuickCategory(user models.User, id, category string) linebot.SendingMessage {
+	e, ok := pendingQuickEntries.Take(user.LineUserID, id)
+	if !ok {
+		return linebot.NewTextMessage("這筆記帳已逾時或已處理，請重新輸入。")
+	}
+	e.Category, e.Exact = category, true
+	return saveQuickEntry(user, e)
+}
+
+// undoQuickEntry deletes the user's latest LINE entry if it is recent enough
+func undoQuickEntry(user models.User) string {
+	var t models.Transaction
+	err := database.DB.Where("user_id = ? AND origin = ? AND created_at > ?", user.ID, models.OriginLine, time.Now().Add(-quickEntryUndoWindow)).
+		Order("created_at DESC").First(&t).Error
+	if err != nil {
+		return fmt.Sprintf("沒有可取消的記帳（僅限 %d 分鐘內以 LINE 新增的紀錄）。", int(quickEntryUndoWindow.Minutes()))
+	}
+	if err := deleteTransaction(user.ID, t); err != nil {
+		utils.LogError("undoQuickEntry: deleteTransaction failed", err)
+		return "取消失敗，請稍後再試。"
+	}
+	return fmt.Sprintf("已取消：%s %s %s", t.Category, formatLineAmount(t.Amount, t.Currency), t.Currency)
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"ledger-lens/backend/models"
+
+	"github.com/google/uuid"
+)
+
+func TestParseQuickEntry(t *testing.T) {
+	cases := []struct {
+		text string
+		want quickEntry
+		ok   bool
+	}{
+		{"午餐 120", quickEntry{Category: "午餐", Amount: "120"}, true},
+		{"午餐120", quickEntry{Category: "午餐", Amount: "120"}, true},
+		{"計程車 350 信用卡", quickEntry{Category: "計程車", Amount: "350", Account: "信用卡"}, true},
+		{"+薪水 50000", quickEntry{Type: "收", Category: "薪水", Amount: "50000"}, true},
+		{"＋ 薪水　５００００", quickEntry{Type: "收", Category: "薪水", Amount: "50000"}, true},
+		{"120 早餐 現金 蛋餅 豆漿", quickEntry{Category: "早餐", Amount: "120", Account: "現金", Note: "蛋餅 豆漿"}, true},
+		{"咖啡 4.5 USD atm", quickEntry{Category: "咖啡", Amount: "4.5", Currency: "USD", Account: "atm"}, true},
+		{"晚餐 1,200 美元", quickEntry{Category: "晚餐", Amount: "1,200", Currency: "USD"}, true},
+		{"7-11 85", quickEntry{Category: "7-11", Amount: "85"}, true},
+		{"說明", quickEntry{}, false},
+		{"120", quickEntry{}, false},
+		{"今天好累", quickEntry{}, false},
+	}
+	for _, tc := range cases {
+		got, ok := parseQuickEntry(tc.text)
+		if ok != tc.ok || (ok && got != tc.want) {
+			t.Errorf("parseQuickEntry(%q) = %+v, %v; want %+v, %v", tc.text, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestQuickTransaction(t *testing.T) {
+	usage := []categoryUsage{
+		{Category: "午餐", MainCategory: "餐飲", Type: "支", Account: "現金", Count: 40},
+		{Category: "計程車", MainCategory: "交通", Type: "支", Account: "信用卡", Count: 12},
+		{Category: "晚餐", MainCategory: "餐飲", Type: "支", Account: "現金", Count: 9},
+		{Category: "薪水", MainCategory: "收入", Type: "收", Account: "薪轉戶", Count: 3},
+	}
+	settings := models.DefaultUserSettings(uuid.New())
+
+	cases := []struct {
+		entry    quickEntry
+		category string
+		main     string
+		typ      string
+		account  string
+		amount   models.Money
+		known    bool
+	}{
+		{quickEntry{Category: "午餐", Amount: "120"}, "午餐", "餐飲", "支", "現金", 120, true},
+		{quickEntry{Category: "計程", Amount: "350", Account: "信用"}, "計程車", "交通", "支", "信用卡", 350, true},
+		{quickEntry{Category: "計乘車", Amount: "350"}, "計程車", "交通", "支", "信用卡", 350, true},
+		{quickEntry{Category: "薪水", Amount: "50000"}, "薪水", "收入", "收", "薪轉戶", 50000, true},
+		{quickEntry{Category: "餐飲", Amount: "80"}, "午餐", "餐飲", "支", "現金", 80, true},
+		{quickEntry{Category: "早餐", Amount: "60", Account: "悠遊卡"}, "早餐", "", "支", "悠遊卡", 60, false},
+		{quickEntry{Category: "咖啡", Amount: "4.5", Currency: "USD"}, "咖啡", "", "支", "", 450, false},
+		{quickEntry{Category: "晚餐", Amount: "200", Exact: true}, "晚餐", "餐飲", "支", "現金", 200, true},
+		{quickEntry{Category: "午", Amount: "90", Exact: true}, "午", "", "支", "", 90, false},
+	}
+	for _, tc := range cases {
+		got, known, err := quickTransaction(tc.entry, usage, settings)
+		if err != nil {
+			t.Errorf("quickTransaction(%+v): %v", tc.entry, err)
+			continue
+		}
+		if got.Category != tc.category || got.MainCategory != tc.main || got.Type != tc.typ ||
+			got.Account != tc.account || got.Amount != tc.amount || known != tc.known || got.Origin != models.OriginLine {
+			t.Errorf("quickTransaction(%+v) = %s/%s %s %s %d known=%v", tc.entry, got.Category, got.MainCategory, got.Type, got.Account, got.Amount, known)
+		}
+	}
+
+	if _, _, err := quickTransaction(quickEntry{Category: "午餐", Amount: "12.5"}, usage, settings); err == nil {
+		t.Error("accepted decimals in TWD")
+	}
+	if got, _, _ := quickTransaction(quickEntry{Category: "午餐", Amount: "120", Date: "20240301"}, usage, settings); got.Date != "20240301" {
+		t.Errorf("held entry booked on %s, want 20240301", got.Date)
+	}
+}
+
+func TestCategoryChoices(t *testing.T) {
+	usage := []categoryUsage{
+		{Category: "午餐", MainCategory: "餐飲", Count: 40},
+		{Category: "計程車", MainCategory: "交通", Count: 12},
+		{Category: "晚餐", MainCategory: "餐飲", Count: 9},
+	}
+	cases := []struct {
+		entry   quickEntry
+		want    string
+		unknown bool
+	}{
+		{quickEntry{Category: "午餐"}, "", false},
+		{quickEntry{Category: "計程"}, "", false},
+		{quickEntry{Category: "餐飲"}, "午餐,晚餐", false},
+		{quickEntry{Category: "早餐"}, "午餐,計程車,晚餐,早餐", true},
+		{quickEntry{Category: "早餐", Exact: true}, "", false},
+	}
+	for _, tc := range cases {
+		got, unknown := categoryChoices(tc.entry, usage)
+		if strings.Join(got, ",") != tc.want || unknown != tc.unknown {
+			t.Errorf("categoryChoices(%s) = %v, %v; want %s, %v", tc.entry.Category, got, unknown, tc.want, tc.unknown)
+		}
+	}
+	if got, unknown := categoryChoices(quickEntry{Category: "明天"}, nil); strings.Join(got, ",") != "明天" || !unknown {
+		t.Errorf("without any history: %v, %v; want the typed name to confirm", got, unknown)
+	}
+
+	many := make([]categoryUsage, 20)
+	for i := range many {
+		many[i] = categoryUsage{Category: fmt.Sprintf("類別%d", i)}
+	}
+	got, _ := categoryChoices(quickEntry{Category: "早餐"}, many)
+	if len(got) != maxCategoryChoices || got[len(got)-1] != "早餐" {
+		t.Errorf("choices = %v, want %d ending with the typed name", got, maxCategoryChoices)
+	}
+}
+
+func TestCategoryPrompt(t *testing.T) {
+	tx := models.Transaction{Amount: 60, Currency: "TWD"}
+	data, err := json.Marshal(categoryPrompt(tx, "早餐", []string{"午餐", "早餐"}, true, "abc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg struct {
+		Text       string
+		QuickReply struct {
+			Items []struct {
+				Action struct {
+					Label, Data, DisplayText string
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Text != "找不到「早餐 60 TWD」的類別，請選擇常用類別或新增：" {
+		t.Errorf("text = %s", msg.Text)
+	}
+	items := msg.QuickReply.Items
+	if len(items) != 2 || items[1].Action.Label != "新增「早餐」" || items[1].Action.DisplayText != "早餐" {
+		t.Fatalf("buttons = %+v", items)
+	}
+	values, _ := url.ParseQuery(items[0].Action.Data)
+	if values.Get("action") != postbackQuickCategory || values.Get("id") != "abc" || values.Get("category") != "午餐" {
+		t.Errorf("postback data = %s", items[0].Action.Data)
+	}
+}
+
+func TestPendingQuickEntries(t *testing.T) {
+	p := &PendingQuickEntries{Entries: make(map[string]pendingEntry)}
+	old := p.Hold("U1", quickEntry{Category: "早餐", Amount: "60"})
+	id := p.Hold("U1", quickEntry{Category: "宵夜", Amount: "90"})
+
+	if _, ok := p.Take("U1", old); ok {
+		t.Error("buttons of a replaced entry still work")
+	}
+	if e, ok := p.Take("U1", id); !ok || e.Category != "宵夜" {
+		t.Errorf("Take = %+v, %v", e, ok)
+	}
+	if _, ok := p.Take("U1", id); ok {
+		t.Error("entry taken twice")
+	}
+
+	id = p.Hold("U2", quickEntry{Category: "早餐", Amount: "60"})
+	pending := p.Entries["U2"]
+	pending.ExpiresAt = time.Now().Add(-time.Second)
+	p.Entries["U2"] = pending
+	if _, ok := p.Take("U2", id); ok {
+		t.Error("expired entry taken")
+	}
+}
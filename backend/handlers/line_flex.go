@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+
+	"ledger-lens/backend/models"
+	"ledger-lens/backend/utils"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot"
+)
+
+// Report card limits; a bubble must stay under LINE's 30 KB
+const (
+	maxCardCategories = 5
+	maxCardBudgets    = 8
+	maxAltTextRunes   = 400
+)
+
+// Report card colours
+const (
+	flexIncomeColor  = "#1DB446"
+	flexExpenseColor = "#E5484D"
+	flexWarnColor    = "#F5A623"
+	flexBarColor     = "#4A90E2"
+	flexMutedColor   = "#999999"
+	flexTrackColor   = "#EEEEEE"
+)
+
+// flexText is a single-line text component
+func flexText(text string, size linebot.FlexTextSizeType, color string) *linebot.TextComponent {
+	return &linebot.TextComponent{Type: linebot.FlexComponentTypeText, Text: text, Size: size, Color: color}
+}
+
+// flexBox lays out components in a box
+func flexBox(layout linebot.FlexBoxLayoutType, contents ...linebot.FlexComponent) *linebot.BoxComponent {
+	if contents == nil {
+		contents = []linebot.FlexComponent{}
+	}
+	return &linebot.BoxComponent{Type: linebot.FlexComponentTypeBox, Layout: layout, Contents: contents}
+}
+
+// flexRow puts a label on the left and a value on the right
+func flexRow(label, value, color string) *linebot.BoxComponent {
+	left := flexText(label, linebot.FlexTextSizeTypeSm, flexMutedColor)
+	right := flexText(value, linebot.FlexTextSizeTypeSm, color)
+	right.Align = linebot.FlexComponentAlignTypeEnd
+	right.Weight = linebot.FlexTextWeightTypeBold
+	return flexBox(linebot.FlexBoxLayoutTypeHorizontal, left, right)
+}
+
+// flexBar is a progress bar filled to percent, clamped to 0–100
+func flexBar(percent float64, color string) *linebot.BoxComponent {
+	percent = math.Max(0, math.Min(100, percent))
+	fill := flexBox(linebot.FlexBoxLayoutTypeVertical)
+	fill.Width = fmt.Sprintf("%.0f%%", percent)
+	fill.Height = "6px"
+	fill.BackgroundColor = color
+	track := flexBox(linebot.FlexBoxLayoutTypeVertical, fill)
+	track.Height = "6px"
+	track.BackgroundColor = flexTrackColor
+	track.Margin = linebot.FlexComponentMarginTypeXs
+	return track
+}
+
+// flexHeader is a bubble header with a title and a muted subtitle
+func flexHeader(title, subtitle string) *linebot.BoxComponent {
+	heading := flexText(title, linebot.FlexTextSizeTypeLg, "")
+	heading.Weight = linebot.FlexTextWeightTypeBold
+	header := flexBox(linebot.FlexBoxLayoutTypeVertical, heading)
+	if subtitle != "" {
+		header.Contents = append(header.Contents, flexText(subtitle, linebot.FlexTextSizeTypeXs, flexMutedColor))
+	}
+	return header
+}
+
+// summaryBubble shows income, expense and balance for a category summary,
+// with the top expense categories as bars of their share of spending
+func summaryBubble(title string, s *Summary, from, to string) *linebot.BubbleContainer {
+	cur := s.BaseCurrency
+	body := flexBox(linebot.FlexBoxLayoutTypeVertical)
+	body.Spacing = linebot.FlexComponentSpacingTypeSm
+	bubble := &linebot.BubbleContainer{
+		Type:   linebot.FlexContainerTypeBubble,
+		Header: flexHeader(title, formatLineRange(from, to)),
+		Body:   body,
+	}
+	if s.Totals.Count == 0 {
+		body.Contents = append(body.Contents, flexText("沒有紀錄。", linebot.FlexTextSizeTypeSm, flexMutedColor))
+		return bubble
+	}
+
+	netColor := flexIncomeColor
+	if s.Totals.Net() < 0 {
+		netColor = flexExpenseColor
+	}
+	body.Contents = append(body.Contents,
+		flexRow("收入", formatLineAmount(s.Totals.Income, cur)+" "+cur, flexIncomeColor),
+		flexRow("支出", formatLineAmount(s.Totals.Expense, cur)+" "+cur, flexExpenseColor),
+		flexRow("結餘", formatLineAmount(s.Totals.Net(), cur)+" "+cur, netColor))
+
+	var top []*SummaryGroup
+	for _, g := range s.Groups {
+		if g.Totals.Expense > 0 {
+			top = append(top, g)
+		}
+	}
+	if len(top) > 0 {
+		caption := flexText("支出類別", linebot.FlexTextSizeTypeSm, "")
+		caption.Weight = linebot.FlexTextWeightTypeBold
+		body.Contents = append(body.Contents, &linebot.SeparatorComponent{Type: linebot.FlexComponentTypeSeparator, Margin: linebot.FlexComponentMarginTypeMd}, caption)
+	}
+	for i, g := range top {
+		if i == maxCardCategories {
+			body.Contents = append(body.Contents, flexText(fmt.Sprintf("其他 %d 類", len(top)-i), linebot.FlexTextSizeTypeXs, flexMutedColor))
+			break
+		}
+		name := g.Key
+		if name == "" {
+			name = "未分類"
+		}
+		share := float64(g.Totals.Expense) / float64(s.Totals.Expense) * 100
+		body.Contents = append(body.Contents, flexBox(linebot.FlexBoxLayoutTypeVertical,
+			flexRow(name, fmt.Sprintf("%s（%.0f%%）", formatLineAmount(g.Totals.Expense, cur), share), ""),
+			flexBar(share, flexBarColor)))
+	}
+
+	footnote := fmt.Sprintf("共 %d 筆", s.Totals.Count)
+	if s.Unconverted > 0 {
+		footnote += fmt.Sprintf("，另有 %d 筆缺少匯率未計入", s.Unconverted)
+	}
+	note := flexText(footnote, linebot.FlexTextSizeTypeXs, flexMutedColor)
+	note.Wrap = true
+	body.Contents = append(body.Contents, note)
+	return bubble
+}
+
+// budgetColor is green below the warning threshold, amber from it and red over budget
+func budgetColor(s BudgetStatus, warnPercent int) string {
+	switch p := s.Percent(); {
+	case s.OverBudget():
+		return flexExpenseColor
+	case p != nil && *p >= float64(warnPercent):
+		return flexWarnColor
+	default:
+		return flexIncomeColor
+	}
+}
+
+// budgetBubble shows each budget's spending against its limit as a bar
+func budgetBubble(statuses []BudgetStatus, currency string, warnPercent int) *linebot.BubbleContainer {
+	body := flexBox(linebot.FlexBoxLayoutTypeVertical)
+	body.Spacing = linebot.FlexComponentSpacingTypeMd
+	for i, s := range statuses {
+		if i == maxCardBudgets {
+			body.Contents = append(body.Contents, flexText(fmt.Sprintf("另有 %d 項預算", len(statuses)-i), linebot.FlexTextSizeTypeXs, flexMutedColor))
+			break
+		}
+		label := fmt.Sprintf("%s（%s）", s.Budget.Category, budgetPeriodLabels[s.Budget.Period])
+		if !s.Converted {
+			body.Contents = append(body.Contents, flexRow(label, "缺少 "+s.Budget.Currency+" 匯率", flexMutedColor))
+			continue
+		}
+		percent := 100.0
+		if p := s.Percent(); p != nil {
+			percent = *p
+		}
+		status := fmt.Sprintf("剩餘 %s", formatLineAmount(s.Remaining(), currency))
+		if s.OverBudget() {
+			status = fmt.Sprintf("超支 %s", formatLineAmount(s.Remaining().Abs(), currency))
+		}
+		color := budgetColor(s, warnPercent)
+		body.Contents = append(body.Contents, flexBox(linebot.FlexBoxLayoutTypeVertical,
+			flexRow(label, fmt.Sprintf("%.0f%%", percent), color),
+			flexBar(percent, color),
+			flexRow(fmt.Sprintf("%s / %s", formatLineAmount(s.Spent, currency), formatLineAmount(s.Available(), currency)), status, "")))
+	}
+	return &linebot.BubbleContainer{
+		Type:   linebot.FlexContainerTypeBubble,
+		Header: flexHeader("預算進度", currency),
+		Body:   body,
+	}
+}
+
+// reportContainer is the summary bubble alone, or a carousel with the budgets after it
+func reportContainer(summary *linebot.BubbleContainer, budgets *linebot.BubbleContainer) linebot.FlexContainer {
+	if budgets == nil {
+		return summary
+	}
+	return &linebot.CarouselContainer{Type: linebot.FlexContainerTypeCarousel, Contents: []*linebot.BubbleContainer{summary, budgets}}
+}
+
+// truncateRunes shortens s to n runes, marking the cut with …
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+// reportCard builds the report card for a query's window. Budgets are added
+// when the window reaches today, since they only cover current periods.
+func reportCard(user models.User, q lineQuery) (*linebot.FlexMessage, error) {
+	settings, err := loadUserSettings(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	today := settings.Now()
+	from, to, err := resolveDateWindow(q.Window, today, settings.MonthStartDay)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := loadSummary(settings, TransactionFilter{From: from, To: to}, GroupByCategory)
+	if err != nil {
+		return nil, err
+	}
+
+	var budgets *linebot.BubbleContainer
+	if to >= today.Format(utils.StoredDateLayout) {
+		statuses, _, err := loadBudgetStatuses(settings)
+		if err != nil {
+			return nil, err
+		}
+		if len(statuses) > 0 {
+			budgets = budgetBubble(statuses, settings.BaseCurrency, settings.BudgetWarnPercent)
+		}
+	}
+
+	q.Subject = lineSubjectBalance
+	altText := truncateRunes(formatLineSummary(q, summary, from, to), maxAltTextRunes)
+	return linebot.NewFlexMessage(altText, reportContainer(summaryBubble(q.Label+"報表", summary, from, to), budgets)), nil
+}
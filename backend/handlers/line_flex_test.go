@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"ledger-lens/backend/models"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot"
+)
+
+// Limits and value formats from the LINE Flex Message reference
+var (
+	flexColorPattern  = regexp.MustCompile(`^#[0-9a-fA-F]{6}([0-9a-fA-F]{2})?$`)
+	flexLengthPattern = regexp.MustCompile(`^\d+(\.\d+)?(%|px)$`)
+	flexSpacings      = []string{"none", "xs", "sm", "md", "lg", "xl", "xxl"}
+	flexTextSizes     = []string{"xxs", "xs", "sm", "md", "lg", "xl", "xxl", "3xl", "4xl", "5xl"}
+	flexBoxChildren   = []string{"box", "button", "image", "text", "separator", "filler", "spacer", "video"}
+	flexBaseline      = []string{"text", "filler", "icon", "spacer"}
+	flexKeys          = map[string][]string{
+		"bubble":    {"type", "size", "direction", "header", "hero", "body", "footer", "styles", "action"},
+		"carousel":  {"type", "contents"},
+		"box":       {"type", "layout", "contents", "flex", "spacing", "margin", "width", "maxWidth", "height", "maxHeight", "cornerRadius", "backgroundColor", "borderColor", "borderWidth", "justifyContent", "alignItems", "paddingAll", "paddingTop", "paddingBottom", "paddingStart", "paddingEnd", "position", "offsetTop", "offsetBottom", "offsetStart", "offsetEnd", "action", "background"},
+		"text":      {"type", "text", "contents", "flex", "margin", "size", "align", "gravity", "wrap", "lineSpacing", "maxLines", "weight", "color", "style", "decoration", "adjustMode", "position", "offsetTop", "offsetBottom", "offsetStart", "offsetEnd", "action"},
+		"separator": {"type", "margin", "color"},
+		"filler":    {"type", "flex"},
+	}
+)
+
+// checkFlex validates marshalled Flex JSON against the schema rules the
+// report cards rely on and returns every violation
+func checkFlex(data []byte) []string {
+	var root map[string]any
+	if err := json.Unmarshal(data, &root); err != nil {
+		return []string{err.Error()}
+	}
+	var errs []string
+	fail := func(path, format string, args ...any) {
+		errs = append(errs, path+": "+fmt.Sprintf(format, args...))
+	}
+	oneOf := func(path, key string, v any, allowed []string) {
+		s, ok := v.(string)
+		if !ok || !contains(allowed, s) && !flexLengthPattern.MatchString(s) {
+			fail(path, "%s %v not in %v", key, v, allowed)
+		}
+	}
+
+	var component func(path string, c map[string]any, parent string)
+	component = func(path string, c map[string]any, parent string) {
+		typ, _ := c["type"].(string)
+		keys, known := flexKeys[typ]
+		if !known {
+			fail(path, "unsupported type %q", typ)
+			return
+		}
+		if parent == "baseline" && !contains(flexBaseline, typ) {
+			fail(path, "%s not allowed in a baseline box", typ)
+		}
+		for k, v := range c {
+			if !contains(keys, k) {
+				fail(path, "unknown property %s", k)
+			}
+			switch k {
+			case "color", "backgroundColor", "borderColor":
+				if s, _ := v.(string); !flexColorPattern.MatchString(s) {
+					fail(path, "%s %v is not a hex colour", k, v)
+				}
+			case "width", "height", "maxWidth", "maxHeight":
+				if s, _ := v.(string); !flexLengthPattern.MatchString(s) {
+					fail(path, "%s %v is not a length", k, v)
+				}
+			case "margin", "spacing":
+				oneOf(path, k, v, flexSpacings)
+			case "flex":
+				if n, _ := v.(float64); n < 0 {
+					fail(path, "negative flex")
+				}
+			}
+		}
+
+		switch typ {
+		case "box":
+			layout, _ := c["layout"].(string)
+			if !contains([]string{"horizontal", "vertical", "baseline"}, layout) {
+				fail(path, "layout %q", layout)
+			}
+			children, ok := c["contents"].([]any)
+			if !ok {
+				fail(path, "box without contents")
+			}
+			for i, child := range children {
+				m, _ := child.(map[string]any)
+				if t, _ := m["type"].(string); !contains(flexBoxChildren, t) && layout != "baseline" {
+					fail(path, "%s not allowed in a box", t)
+				}
+				component(fmt.Sprintf("%s.contents[%d]", path, i), m, layout)
+			}
+		case "text":
+			if s, _ := c["text"].(string); strings.TrimSpace(s) == "" && c["contents"] == nil {
+				fail(path, "empty text")
+			}
+			if size, ok := c["size"]; ok {
+				oneOf(path, "size", size, flexTextSizes)
+			}
+		}
+	}
+
+	bubble := func(path string, b map[string]any) {
+		if t, _ := b["type"].(string); t != "bubble" {
+			fail(path, "expected bubble, got %q", t)
+			return
+		}
+		for k := range b {
+			if !contains(flexKeys["bubble"], k) {
+				fail(path, "unknown property %s", k)
+			}
+		}
+		for _, block := range []string{"header", "hero", "body", "footer"} {
+			if m, ok := b[block].(map[string]any); ok {
+				if block != "hero" && m["type"] != "box" {
+					fail(path+"."+block, "block must be a box")
+				}
+				component(path+"."+block, m, "")
+			}
+		}
+		if size, _ := json.Marshal(b); len(size) > 30*1024 {
+			fail(path, "bubble is %d bytes, over 30 KB", len(size))
+		}
+	}
+
+	switch root["type"] {
+	case "bubble":
+		bubble("bubble", root)
+	case "carousel":
+		items, _ := root["contents"].([]any)
+		if len(items) == 0 || len(items) > 12 {
+			fail("carousel", "%d bubbles, want 1–12", len(items))
+		}
+		for i, item := range items {
+			m, _ := item.(map[string]any)
+			bubble(fmt.Sprintf("carousel[%d]", i), m)
+		}
+		if len(data) > 50*1024 {
+			fail("carousel", "%d bytes, over 50 KB", len(data))
+		}
+	default:
+		fail("root", "unsupported container %v", root["type"])
+	}
+	return errs
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// assertFlex fails the test unless container is valid Flex JSON that the
+// SDK reads back
+func assertFlex(t *testing.T, name string, container linebot.FlexContainer) {
+	t.Helper()
+	data, err := json.Marshal(container)
+	if err != nil {
+		t.Fatalf("%s: marshal: %v", name, err)
+	}
+	for _, e := range checkFlex(data) {
+		t.Errorf("%s: %s", name, e)
+	}
+	if _, err := linebot.UnmarshalFlexMessageJSON(data); err != nil {
+		t.Errorf("%s: SDK cannot read the card back: %v", name, err)
+	}
+}
+
+func TestReportCardsFollowFlexSchema(t *testing.T) {
+	converter := &currencyConverter{base: "TWD"}
+	rows := []models.Transaction{{Date: "20240305", Type: "收", Category: "薪水", Amount: 50000, Currency: "TWD"}}
+	for i, cat := range []string{"午餐", "晚餐", "交通", "房租", "娛樂", "日用品", "", "醫療"} {
+		rows = append(rows, models.Transaction{Date: "20240302", Type: "支", Category: cat, Amount: models.Money(1000 * (i + 1)), Currency: "TWD"})
+	}
+	rows = append(rows, models.Transaction{Date: "20240302", Type: "支", Category: "旅遊", Amount: 100, Currency: "JPY"})
+	summary := summarize(rows, converter, GroupByCategory, 1)
+	empty := summarize(nil, converter, GroupByCategory, 1)
+
+	over := BudgetStatus{Budget: models.Budget{Category: "餐飲", Period: models.BudgetPeriodMonth, Currency: "TWD"}, Limit: 1000, Spent: 1500, Converted: true}
+	warn := BudgetStatus{Budget: models.Budget{Category: "交通", Period: models.BudgetPeriodWeek, Currency: "TWD"}, Limit: 1000, Spent: 850, Converted: true}
+	zero := BudgetStatus{Budget: models.Budget{Category: "娛樂", Period: models.BudgetPeriodYear, Currency: "TWD"}, Spent: 10, Converted: true}
+	missing := BudgetStatus{Budget: models.Budget{Category: "旅遊", Period: models.BudgetPeriodMonth, Currency: "JPY"}}
+	statuses := []BudgetStatus{over, warn, zero, missing}
+	for len(statuses) <= maxCardBudgets {
+		statuses = append(statuses, warn)
+	}
+
+	assertFlex(t, "summary", summaryBubble("本月報表", summary, "20240301", "20240331"))
+	assertFlex(t, "empty summary", summaryBubble("本月報表", empty, "20240301", "20240331"))
+	assertFlex(t, "carousel", reportContainer(
+		summaryBubble("本月報表", summary, "20240301", "20240331"),
+		budgetBubble(statuses, "TWD", models.DefaultBudgetWarnPercent)))
+}
+
+func TestCheckFlexRejectsInvalidCards(t *testing.T) {
+	cases := map[string]string{
+		"layout":   `{"type":"bubble","body":{"type":"box","layout":"grid","contents":[]}}`,
+		"contents": `{"type":"bubble","body":{"type":"box","layout":"vertical"}}`,
+		"text":     `{"type":"bubble","body":{"type":"box","layout":"vertical","contents":[{"type":"text","text":""}]}}`,
+		"colour":   `{"type":"bubble","body":{"type":"box","layout":"vertical","contents":[],"backgroundColor":"red"}}`,
+		"width":    `{"type":"bubble","body":{"type":"box","layout":"vertical","contents":[],"width":"half"}}`,
+		"baseline": `{"type":"bubble","body":{"type":"box","layout":"baseline","contents":[{"type":"box","layout":"vertical","contents":[]}]}}`,
+		"carousel": `{"type":"carousel","contents":[]}`,
+	}
+	for name, data := range cases {
+		if errs := checkFlex([]byte(data)); len(errs) == 0 {
+			t.Errorf("%s: invalid card accepted", name)
+		}
+	}
+}
+
+func TestSummaryBubbleBars(t *testing.T) {
+	rows := []models.Transaction{
+		{Date: "20240302", Type: "支", Category: "午餐", Amount: 750, Currency: "TWD"},
+		{Date: "20240302", Type: "支", Category: "交通", Amount: 250, Currency: "TWD"},
+	}
+	s := summarize(rows, &currencyConverter{base: "TWD"}, GroupByCategory, 1)
+	data, err := json.Marshal(summaryBubble("本月報表", s, "20240301", "20240331"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"width":"75%"`, `"width":"25%"`, `"text":"750（75%）"`, `"text":"2024/03/01–03/31"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("card is missing %s", want)
+		}
+	}
+}
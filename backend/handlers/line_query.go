@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"ledger-lens/backend/database"
+	"ledger-lens/backend/models"
+	"ledger-lens/backend/utils"
+)
+
+// LINE query limits
+const (
+	defaultRecentEntries = 5
+	maxRecentEntries     = 20
+	maxQueryCategories   = 5
+)
+
+// Kinds of LINE query
+const (
+	lineQuerySummary = "summary"
+	lineQueryBudgets = "budgets"
+	lineQueryRecent  = "recent"
+	lineQueryReport  = "report"
+)
+
+// What a summary query asks for when it does not name a category
+const (
+	lineSubjectExpense = "支出"
+	lineSubjectIncome  = "收入"
+	lineSubjectBalance = "收支"
+)
+
+// lineWindows maps the period words of LINE queries to date windows,
+// longest first so 上個月 is not read as 上
+var lineWindows = []struct{ word, window string }{
+	{"這個月", "this_month"},
+	{"上個月", "last_month"},
+	{"今天", "today"},
+	{"昨天", "yesterday"},
+	{"本週", "this_week"},
+	{"這週", "this_week"},
+	{"上週", "last_week"},
+	{"本月", "this_month"},
+	{"上月", "last_month"},
+	{"今年", "this_year"},
+	{"去年", "last_year"},
+}
+
+var lineRecentPattern = regexp.MustCompile(`^最近\s*(\d{1,3})?\s*筆$`)
+
+// lineQuery is a question typed to the bot
+type lineQuery struct {
+	Kind    string
+	Label   string // period word as typed, e.g. 上週
+	Window  string // for resolveDateWindow
+	Subject string // 支出, 收入, 收支 or a category
+	Limit   int    // rows for recent
+}
+
+// parseLineQuery reads 本月支出, 上週餐飲, 今年收支, 預算, 最近10筆 and
+// 報表 or 上月報表 for a report card. Other text is not a query.
+func parseLineQuery(text string) (lineQuery, bool) {
+	text = strings.TrimSpace(utils.HalfWidth(text))
+	if text == "預算" {
+		return lineQuery{Kind: lineQueryBudgets}, true
+	}
+	if m := lineRecentPattern.FindStringSubmatch(text); m != nil {
+		n := defaultRecentEntries
+		if m[1] != "" {
+			n, _ = strconv.Atoi(m[1])
+		}
+		if n < 1 {
+			n = defaultRecentEntries
+		}
+		return lineQuery{Kind: lineQueryRecent, Limit: min(n, maxRecentEntries)}, true
+	}
+	if period, ok := strings.CutSuffix(text, "報表"); ok {
+		if period == "" {
+			return lineQuery{Kind: lineQueryReport, Label: "本月", Window: "this_month"}, true
+		}
+		for _, w := range lineWindows {
+			if period == w.word {
+				return lineQuery{Kind: lineQueryReport, Label: w.word, Window: w.window}, true
+			}
+		}
+		return lineQuery{}, false
+	}
+	for _, w := range lineWindows {
+		if !strings.HasPrefix(text, w.word) {
+			continue
+		}
+		subject := strings.TrimSpace(strings.TrimPrefix(text, w.word))
+		if subject == "" {
+			subject = lineSubjectBalance
+		}
+		if strings.ContainsAny(subject, " 0123456789") {
+			return lineQuery{}, false // 今天午餐 120 is an entry
+		}
+		return lineQuery{Kind: lineQuerySummary, Label: w.word, Window: w.window, Subject: subject}, true
+	}
+	return lineQuery{}, false
+}
+
+// namesCategory reports whether a summary query asks about a category
+// rather than 支出, 收入 or 收支
+func (q lineQuery) namesCategory() bool {
+	switch q.Subject {
+	case lineSubjectExpense, lineSubjectIncome, lineSubjectBalance:
+		return false
+	}
+	return q.Kind == lineQuerySummary
+}
+
+// formatLineAmount renders an amount with thousands separators, e.g. 12,345.50
+func formatLineAmount(m models.Money, currency string) string {
+	s := m.Format(currency)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i:]
+	}
+	var b strings.Builder
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	return sign + b.String() + frac
+}
+
+// formatLineRange renders inclusive YYYYMMDD bounds, e.g. 2024/03/01–03/31
+func formatLineRange(from, to string) string {
+	if from == to {
+		return formatLineDate(from)
+	}
+	end := formatLineDate(to)
+	if from[:4] == to[:4] {
+		end = formatLineShortDate(to)
+	}
+	return formatLineDate(from) + "–" + end
+}
+
+// formatLineSummary answers a summary query from a category summary
+func formatLineSummary(q lineQuery, s *Summary, from, to string) string {
+	cur := s.BaseCurrency
+	header := fmt.Sprintf("%s%s（%s）", q.Label, q.Subject, formatLineRange(from, to))
+	if s.Totals.Count == 0 {
+		return header + "\n沒有紀錄。"
+	}
+	lines := []string{header}
+
+	type line struct {
+		name   string
+		amount models.Money
+	}
+	var top []line
+	switch {
+	case q.Subject == lineSubjectBalance:
+		lines = append(lines,
+			fmt.Sprintf("收入 %s %s", formatLineAmount(s.Totals.Income, cur), cur),
+			fmt.Sprintf("支出 %s %s", formatLineAmount(s.Totals.Expense, cur), cur),
+			fmt.Sprintf("結餘 %s %s", formatLineAmount(s.Totals.Net(), cur), cur))
+	case q.Subject == lineSubjectIncome || (s.Totals.Expense == 0 && s.Totals.Income > 0): // also 今年薪水
+		lines = append(lines, fmt.Sprintf("合計 %s %s", formatLineAmount(s.Totals.Income, cur), cur))
+		for _, g := range s.Groups {
+			if g.Totals.Income > 0 {
+				top = append(top, line{g.Key, g.Totals.Income})
+			}
+		}
+	default:
+		lines = append(lines, fmt.Sprintf("合計 %s %s", formatLineAmount(s.Totals.Expense, cur), cur))
+		for _, g := range s.Groups {
+			if g.Totals.Expense > 0 {
+				top = append(top, line{g.Key, g.Totals.Expense})
+			}
+		}
+	}
+
+	sort.SliceStable(top, func(i, j int) bool { return top[i].amount > top[j].amount })
+	if len(top) > 1 {
+		for i, l := range top {
+			if i == maxQueryCategories {
+				lines = append(lines, "…")
+				break
+			}
+			lines = append(lines, fmt.Sprintf("%d. %s %s", i+1, l.name, formatLineAmount(l.amount, cur)))
+		}
+	}
+	lines = append(lines, fmt.Sprintf("共 %d 筆", s.Totals.Count))
+	if s.Unconverted > 0 {
+		lines = append(lines, fmt.Sprintf("另有 %d 筆缺少 %s 匯率未計入", s.Unconverted, strings.Join(s.MissingRates, "、")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatLineBudgets lists every budget's standing
+func formatLineBudgets(statuses []BudgetStatus, currency string) string {
+	if len(statuses) == 0 {
+		return "尚未設定預算，請至網頁新增。"
+	}
+	lines := []string{"預算"}
+	for _, s := range statuses {
+		label := fmt.Sprintf("%s（%s）", s.Budget.Category, budgetPeriodLabels[s.Budget.Period])
+		if !s.Converted {
+			lines = append(lines, fmt.Sprintf("%s：缺少 %s 匯率", label, s.Budget.Currency))
+			continue
+		}
+		text := fmt.Sprintf("%s：%s / %s", label, formatLineAmount(s.Spent, currency), formatLineAmount(s.Available(), currency))
+		if p := s.Percent(); p != nil {
+			text += fmt.Sprintf("（%.0f%%）", *p)
+		}
+		if s.OverBudget() {
+			text += fmt.Sprintf("，超支 %s", formatLineAmount(s.Remaining().Abs(), currency))
+		} else {
+			text += fmt.Sprintf("，剩餘 %s", formatLineAmount(s.Remaining(), currency))
+		}
+		lines = append(lines, text)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatLineRecent lists transactions newest first, income marked with +
+func formatLineRecent(rows []models.Transaction) string {
+	if len(rows) == 0 {
+		return "帳本中沒有紀錄。"
+	}
+	lines := []string{fmt.Sprintf("最近 %d 筆", len(rows))}
+	for _, t := range rows {
+		sign := ""
+		if t.Type == "收" {
+			sign = "+"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s %s%s %s", formatLineShortDate(t.Date), t.Category, sign, formatLineAmount(t.Amount, t.Currency), t.Currency))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// answerLineQuery runs a query over the user's ledger and returns the reply.
+// A category subject must already be resolved by resolveQueryCategory.
+func answerLineQuery(user models.User, q lineQuery) string {
+	settings, err := loadUserSettings(user.ID)
+	if err != nil {
+		utils.LogError("answerLineQuery: loadUserSettings failed", err)
+		return "查詢失敗，請稍後再試。"
+	}
+
+	switch q.Kind {
+	case lineQueryBudgets:
+		statuses, _, err := loadBudgetStatuses(settings)
+		if err != nil {
+			utils.LogError("answerLineQuery: loadBudgetStatuses failed", err)
+			return "查詢失敗，請稍後再試。"
+		}
+		return formatLineBudgets(statuses, settings.BaseCurrency)
+
+	case lineQueryRecent:
+		var rows []models.Transaction
+		if err := database.DB.Scopes(ledgerScope(user.ID)).
+			Order("date DESC, created_at DESC").Limit(q.Limit).Find(&rows).Error; err != nil {
+			utils.LogError("answerLineQuery: DB Find failed", err)
+			return "查詢失敗，請稍後再試。"
+		}
+		return formatLineRecent(rows)
+	}
+
+	from, to, err := resolveDateWindow(q.Window, settings.Now(), settings.MonthStartDay)
+	if err != nil {
+		return err.Error()
+	}
+	filter := TransactionFilter{From: from, To: to}
+	switch q.Subject {
+	case lineSubjectExpense:
+		filter.Types = []string{"支"}
+	case lineSubjectIncome:
+		filter.Types = []string{"收"}
+	case lineSubjectBalance:
+	default:
+		filter.Query = "category:" + quoteQuery(q.Subject)
+	}
+
+	summary, err := loadSummary(settings, filter, GroupByCategory)
+	if err != nil {
+		utils.LogError("answerLineQuery: loadSummary failed", err)
+		return "查詢失敗，請稍後再試。"
+	}
+	return formatLineSummary(q, summary, from, to)
+}
+
+// resolveQueryCategory finds the category or main category a query names
+func resolveQueryCategory(input string, usage []categoryUsage) (string, bool) {
+	var names []string
+	seen := map[string]bool{}
+	for _, u := range usage {
+		for _, name := range []string{u.MainCategory, u.Category} {
+			if name != "" && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	matched := fuzzyMatch(input, names)
+	if len(matched) == 0 {
+		return "", false
+	}
+	return matched[0], true
+}
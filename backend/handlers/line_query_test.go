@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"testing"
+
+	"ledger-lens/backend/models"
+)
+
+func TestParseLineQuery(t *testing.T) {
+	cases := []struct {
+		text string
+		want lineQuery
+		ok   bool
+	}{
+		{"本月支出", lineQuery{Kind: lineQuerySummary, Label: "本月", Window: "this_month", Subject: "支出"}, true},
+		{"上週餐飲", lineQuery{Kind: lineQuerySummary, Label: "上週", Window: "last_week", Subject: "餐飲"}, true},
+		{"上個月收入", lineQuery{Kind: lineQuerySummary, Label: "上個月", Window: "last_month", Subject: "收入"}, true},
+		{"今年", lineQuery{Kind: lineQuerySummary, Label: "今年", Window: "this_year", Subject: "收支"}, true},
+		{"預算", lineQuery{Kind: lineQueryBudgets}, true},
+		{"最近10筆", lineQuery{Kind: lineQueryRecent, Limit: 10}, true},
+		{"最近筆", lineQuery{Kind: lineQueryRecent, Limit: defaultRecentEntries}, true},
+		{"最近 100 筆", lineQuery{Kind: lineQueryRecent, Limit: maxRecentEntries}, true},
+		{"報表", lineQuery{Kind: lineQueryReport, Label: "本月", Window: "this_month"}, true},
+		{"上個月報表", lineQuery{Kind: lineQueryReport, Label: "上個月", Window: "last_month"}, true},
+		{"餐飲報表", lineQuery{}, false},
+		{"今天午餐 120", lineQuery{}, false},
+		{"午餐 120", lineQuery{}, false},
+	}
+	for _, tc := range cases {
+		got, ok := parseLineQuery(tc.text)
+		if ok != tc.ok || got != tc.want {
+			t.Errorf("parseLineQuery(%q) = %+v, %v; want %+v, %v", tc.text, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestFormatLineAmount(t *testing.T) {
+	cases := []struct {
+		m        models.Money
+		currency string
+		want     string
+	}{
+		{0, "TWD", "0"},
+		{999, "TWD", "999"},
+		{1234567, "TWD", "1,234,567"},
+		{-123456, "USD", "-1,234.56"},
+	}
+	for _, tc := range cases {
+		if got := formatLineAmount(tc.m, tc.currency); got != tc.want {
+			t.Errorf("formatLineAmount(%d, %s) = %s, want %s", tc.m, tc.currency, got, tc.want)
+		}
+	}
+}
+
+func TestFormatLineSummary(t *testing.T) {
+	converter := &currencyConverter{base: "TWD"}
+	expenses := []models.Transaction{
+		{Date: "20240302", Type: "支", Category: "午餐", Amount: 1200, Currency: "TWD"},
+		{Date: "20240303", Type: "支", Category: "晚餐", Amount: 450, Currency: "TWD"},
+	}
+	all := append(expenses, models.Transaction{Date: "20240305", Type: "收", Category: "薪水", Amount: 50000, Currency: "TWD"})
+
+	cases := []struct {
+		q    lineQuery
+		rows []models.Transaction
+		want string
+	}{
+		{lineQuery{Label: "本月", Subject: lineSubjectExpense}, expenses,
+			"本月支出（2024/03/01–03/31）\n合計 1,650 TWD\n1. 午餐 1,200\n2. 晚餐 450\n共 2 筆"},
+		{lineQuery{Label: "本月", Subject: lineSubjectBalance}, all,
+			"本月收支（2024/03/01–03/31）\n收入 50,000 TWD\n支出 1,650 TWD\n結餘 48,350 TWD\n共 3 筆"},
+		{lineQuery{Label: "本月", Subject: "薪水"}, all[2:],
+			"本月薪水（2024/03/01–03/31）\n合計 50,000 TWD\n共 1 筆"},
+		{lineQuery{Label: "本月", Subject: "交通"}, nil,
+			"本月交通（2024/03/01–03/31）\n沒有紀錄。"},
+	}
+	for _, tc := range cases {
+		s := summarize(tc.rows, converter, GroupByCategory, 1)
+		if got := formatLineSummary(tc.q, s, "20240301", "20240331"); got != tc.want {
+			t.Errorf("reply =\n%s\nwant\n%s", got, tc.want)
+		}
+	}
+}
+
+func TestFormatLineRecent(t *testing.T) {
+	rows := []models.Transaction{
+		{Date: "20240306", Type: "收", Category: "薪水", Amount: 50000, Currency: "TWD"},
+		{Date: "", Type: "支", Category: "午餐", Amount: 120, Currency: "TWD"},
+		{Date: "3/6", Type: "支", Category: "晚餐", Amount: 200, Currency: "TWD"},
+	}
+	want := "最近 3 筆\n03/06 薪水 +50,000 TWD\n 午餐 120 TWD\n3/6 晚餐 200 TWD"
+	if got := formatLineRecent(rows); got != want {
+		t.Errorf("reply =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestLineQueryNeedsKnownCategory(t *testing.T) {
+	usage := []categoryUsage{{Category: "午餐", MainCategory: "餐飲"}, {Category: "計程車", MainCategory: "交通"}}
+	cases := []struct {
+		text  string
+		query bool // answered as a query
+	}{
+		{"本月支出", true},
+		{"今年", true},
+		{"上週餐飲", true},
+		{"今天午餐", true},
+		{"今天好累", false},
+		{"昨天見", false},
+	}
+	for _, tc := range cases {
+		q, ok := parseLineQuery(tc.text)
+		if !ok {
+			t.Fatalf("parseLineQuery(%q) rejected", tc.text)
+		}
+		answered := true
+		if q.namesCategory() {
+			_, answered = resolveQueryCategory(q.Subject, usage)
+		}
+		if answered != tc.query {
+			t.Errorf("%q answered as a query: %v, want %v", tc.text, answered, tc.query)
+		}
+	}
+}
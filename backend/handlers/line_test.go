@@ -12,11 +12,12 @@ func TestParseCSV(t *testing.T) {
 2023-12-02,Salary,Income,50000,TWD,Me,Bank,,Salary,收,2023-12-02T10:00:00Z,uuid-456`
 
 	reader := strings.NewReader(csvContent)
-	transactions, err := parseCSV(reader)
+	parsed, err := parseCSV(reader, ParseOptions{})
 
 	if err != nil {
 		t.Fatalf("Failed to parse CSV: %v", err)
 	}
+	transactions := parsed.Rows
 
 	if len(transactions) != 2 {
 		t.Errorf("Expected 2 transactions, got %d", len(transactions))
@@ -24,19 +25,19 @@ func TestParseCSV(t *testing.T) {
 
 	// Check first transaction
 	t1 := transactions[0]
-	if t1["date"] != "2023-12-01" {
-		t.Errorf("Expected date 2023-12-01, got %v", t1["date"])
+	if t1.DateRaw != "2023-12-01" {
+		t.Errorf("Expected date 2023-12-01, got %v", t1.DateRaw)
 	}
-	if t1["amount"] != 100.0 {
-		t.Errorf("Expected amount 100, got %v", t1["amount"])
+	if t1.Amount != 100 {
+		t.Errorf("Expected amount 100, got %v", t1.Amount)
 	}
-	if t1["type"] != "支" {
-		t.Errorf("Expected type 支, got %v", t1["type"])
+	if t1.Type != "支" {
+		t.Errorf("Expected type 支, got %v", t1.Type)
 	}
 
 	// Check second transaction
 	t2 := transactions[1]
-	if t2["mainCategory"] != "Income" {
-		t.Errorf("Expected mainCategory Income, got %v", t2["mainCategory"])
+	if t2.MainCategory != "Income" {
+		t.Errorf("Expected mainCategory Income, got %v", t2.MainCategory)
 	}
 }
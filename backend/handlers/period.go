@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"ledger-lens/backend/utils"
+)
+
+var lastNPattern = regexp.MustCompile(`^last_(\d{1,4})_(days|months)$`)
+
+// fiscalMonthStart returns the first day of the month containing d, for
+// months that begin on startDay
+func fiscalMonthStart(d time.Time, startDay int) time.Time {
+	if startDay < 1 {
+		startDay = 1
+	}
+	start := time.Date(d.Year(), d.Month(), startDay, 0, 0, 0, 0, time.UTC)
+	if d.Day() < startDay {
+		start = start.AddDate(0, -1, 0)
+	}
+	return start
+}
+
+// resolveDateWindow turns a relative window into inclusive YYYYMMDD bounds
+// as seen on today: today, yesterday, this_/last_ week, month or year, and
+// last_N_days or last_N_months (months including the current one).
+// Weeks start on Monday, months on monthStartDay; years are calendar years.
+func resolveDateWindow(window string, today time.Time, monthStartDay int) (string, string, error) {
+	day := func(t time.Time) string { return t.Format(utils.StoredDateLayout) }
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	monthStart := fiscalMonthStart(today, monthStartDay)
+	yearStart := time.Date(today.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	weekStart := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+
+	if m := lastNPattern.FindStringSubmatch(window); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		if n < 1 {
+			return "", "", fmt.Errorf("date window %q must cover at least one %s", window, m[2][:len(m[2])-1])
+		}
+		if m[2] == "days" {
+			return day(today.AddDate(0, 0, 1-n)), day(today), nil
+		}
+		return day(monthStart.AddDate(0, 1-n, 0)), day(today), nil
+	}
+
+	switch window {
+	case "today":
+		return day(today), day(today), nil
+	case "yesterday":
+		y := today.AddDate(0, 0, -1)
+		return day(y), day(y), nil
+	case "this_week":
+		return day(weekStart), day(weekStart.AddDate(0, 0, 6)), nil
+	case "last_week":
+		return day(weekStart.AddDate(0, 0, -7)), day(weekStart.AddDate(0, 0, -1)), nil
+	case "this_month":
+		return day(monthStart), day(monthStart.AddDate(0, 1, -1)), nil
+	case "last_month":
+		return day(monthStart.AddDate(0, -1, 0)), day(monthStart.AddDate(0, 0, -1)), nil
+	case "this_year":
+		return day(yearStart), day(yearStart.AddDate(1, 0, -1)), nil
+	case "last_year":
+		return day(yearStart.AddDate(-1, 0, 0)), day(yearStart.AddDate(0, 0, -1)), nil
+	}
+	return "", "", fmt.Errorf("unknown date window %q", window)
+}
+
+// period is a calendar bucket with inclusive YYYYMMDD bounds
+type period struct {
+	Key      string
+	From, To string
+}
+
+// periodOf returns the calendar year ("2024"), month or Monday-based ISO
+// week ("2024-W09") containing d. Months begin on monthStartDay and are keyed
+// by the month they begin in, so with 25 "2024-01" runs from 25 January to
+// 24 February.
+func periodOf(d time.Time, groupBy string, monthStartDay int) period {
+	day := func(t time.Time) string { return t.Format(utils.StoredDateLayout) }
+	d = time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, time.UTC)
+	switch groupBy {
+	case GroupByWeek:
+		start := d.AddDate(0, 0, -((int(d.Weekday()) + 6) % 7))
+		year, week := d.ISOWeek()
+		return period{fmt.Sprintf("%04d-W%02d", year, week), day(start), day(start.AddDate(0, 0, 6))}
+	case GroupByYear:
+		start := time.Date(d.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+		return period{start.Format("2006"), day(start), day(start.AddDate(1, 0, -1))}
+	}
+	start := fiscalMonthStart(d, monthStartDay)
+	return period{start.Format("2006-01"), day(start), day(start.AddDate(0, 1, -1))}
+}
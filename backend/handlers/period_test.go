@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResolveDateWindow(t *testing.T) {
+	today := time.Date(2024, 3, 6, 15, 0, 0, 0, time.UTC) // a Wednesday
+	cases := []struct {
+		window   string
+		from, to string
+	}{
+		{"today", "20240306", "20240306"},
+		{"last_30_days", "20240206", "20240306"},
+		{"last_3_months", "20240101", "20240306"},
+		{"this_week", "20240304", "20240310"},
+		{"last_week", "20240226", "20240303"},
+		{"last_month", "20240201", "20240229"},
+		{"this_year", "20240101", "20241231"},
+	}
+	for _, tc := range cases {
+		from, to, err := resolveDateWindow(tc.window, today, 1)
+		if err != nil || from != tc.from || to != tc.to {
+			t.Errorf("resolveDateWindow(%q) = %s..%s, %v; want %s..%s", tc.window, from, to, err, tc.from, tc.to)
+		}
+	}
+	for _, bad := range []string{"last_0_days", "next_week", ""} {
+		if _, _, err := resolveDateWindow(bad, today, 1); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+}
+
+func TestResolveDateWindowFiscalMonths(t *testing.T) {
+	cases := []struct {
+		today    time.Time
+		window   string
+		from, to string
+	}{
+		{time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC), "this_month", "20240225", "20240324"},
+		{time.Date(2024, 3, 25, 0, 0, 0, 0, time.UTC), "this_month", "20240325", "20240424"},
+		{time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC), "last_month", "20240125", "20240224"},
+		{time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC), "last_2_months", "20231125", "20240110"},
+	}
+	for _, tc := range cases {
+		from, to, err := resolveDateWindow(tc.window, tc.today, 25)
+		if err != nil || from != tc.from || to != tc.to {
+			t.Errorf("resolveDateWindow(%q) on %s = %s..%s, %v; want %s..%s", tc.window, tc.today.Format("20060102"), from, to, err, tc.from, tc.to)
+		}
+	}
+}
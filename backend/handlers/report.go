@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"ledger-lens/backend/database"
+	"ledger-lens/backend/models"
+	"ledger-lens/backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Summary groupings
+const (
+	GroupByYear         = "year"
+	GroupByMonth        = "month"
+	GroupByWeek         = "week"
+	GroupByCategory     = "category"
+	GroupByMainCategory = "mainCategory"
+	GroupByAccount      = "account"
+	GroupByMember       = "member"
+)
+
+// maxBreakdownCategories caps the per-period category breakdown
+const maxBreakdownCategories = 10
+
+// ReportTotals sums a set of transactions in the base currency.
+// Transfers (轉) are counted but belong to neither side.
+type ReportTotals struct {
+	Expense models.Money
+	Income  models.Money
+	Count   int
+}
+
+func (t *ReportTotals) add(typ string, m models.Money) {
+	t.Count++
+	switch typ {
+	case "支":
+		t.Expense += m
+	case "收":
+		t.Income += m
+	}
+}
+
+// Net is income minus expense
+func (t ReportTotals) Net() models.Money {
+	return t.Income - t.Expense
+}
+
+// SummaryGroup is one bucket of a summary. From/To are set for time buckets.
+type SummaryGroup struct {
+	Key        string
+	From, To   string
+	Totals     ReportTotals
+	categories map[string]models.Money // expense by category, time buckets only
+}
+
+// Summary is the result of summarize
+type Summary struct {
+	GroupBy       string
+	BaseCurrency  string
+	MonthStartDay int
+	Totals        ReportTotals
+	Groups        []*SummaryGroup
+	Unconverted   int      // rows left out for lack of an exchange rate
+	MissingRates  []string // their currencies
+}
+
+// isTimeGrouping reports whether groups are periods rather than field values
+func isTimeGrouping(groupBy string) bool {
+	return groupBy == GroupByYear || groupBy == GroupByMonth || groupBy == GroupByWeek
+}
+
+func validGroupBy(groupBy string) bool {
+	switch groupBy {
+	case GroupByYear, GroupByMonth, GroupByWeek, GroupByCategory, GroupByMainCategory, GroupByAccount, GroupByMember:
+		return true
+	}
+	return false
+}
+
+// groupKey returns the bucket of a row and, for time buckets, its bounds
+func groupKey(t models.Transaction, groupBy string, monthStartDay int) (string, string, string) {
+	switch groupBy {
+	case GroupByYear, GroupByMonth, GroupByWeek:
+		d, err := time.Parse(utils.StoredDateLayout, t.Date)
+		if err != nil {
+			return "", "", "" // legacy rows that could not be normalised
+		}
+		p := periodOf(d, groupBy, monthStartDay)
+		return p.Key, p.From, p.To
+	case GroupByCategory:
+		return t.Category, "", ""
+	case GroupByMainCategory:
+		return t.MainCategory, "", ""
+	case GroupByAccount:
+		return t.Account, "", ""
+	case GroupByMember:
+		return t.Member, "", ""
+	}
+	return "", "", ""
+}
+
+// summarize totals rows in the converter's base currency, grouped by groupBy.
+// Months begin on monthStartDay.
+func summarize(rows []models.Transaction, converter *currencyConverter, groupBy string, monthStartDay int) *Summary {
+	s := &Summary{GroupBy: groupBy, BaseCurrency: converter.base, MonthStartDay: monthStartDay}
+	byKey := map[string]*SummaryGroup{}
+	missing := map[string]bool{}
+
+	for _, t := range rows {
+		amount, ok := converter.Convert(t.Amount, t.Currency, t.Date)
+		if !ok {
+			s.Unconverted++
+			missing[models.NormalizeCurrency(t.Currency)] = true
+			continue
+		}
+		s.Totals.add(t.Type, amount)
+
+		key, from, to := groupKey(t, groupBy, monthStartDay)
+		g, ok := byKey[key]
+		if !ok {
+			g = &SummaryGroup{Key: key, From: from, To: to, categories: map[string]models.Money{}}
+			byKey[key] = g
+			s.Groups = append(s.Groups, g)
+		}
+		g.Totals.add(t.Type, amount)
+		if t.Type == "支" {
+			g.categories[t.Category] += amount
+		}
+	}
+
+	if isTimeGrouping(groupBy) {
+		sort.Slice(s.Groups, func(i, j int) bool { return s.Groups[i].Key < s.Groups[j].Key })
+	} else {
+		sort.Slice(s.Groups, func(i, j int) bool {
+			a, b := s.Groups[i].Totals, s.Groups[j].Totals
+			if a.Expense != b.Expense {
+				return a.Expense > b.Expense
+			}
+			if a.Income != b.Income {
+				return a.Income > b.Income
+			}
+			return s.Groups[i].Key < s.Groups[j].Key
+		})
+	}
+
+	s.MissingRates = make([]string, 0, len(missing))
+	for cur := range missing {
+		s.MissingRates = append(s.MissingRates, cur)
+	}
+	sort.Strings(s.MissingRates)
+	return s
+}
+
+// CategoryAmount is one line of a category breakdown
+type CategoryAmount struct {
+	Category string
+	Amount   models.Money
+}
+
+// topCategories returns the largest expense categories of a group, biggest first
+func (g *SummaryGroup) topCategories(n int) []CategoryAmount {
+	out := make([]CategoryAmount, 0, len(g.categories))
+	for cat, m := range g.categories {
+		out = append(out, CategoryAmount{cat, m})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Amount != out[j].Amount {
+			return out[i].Amount > out[j].Amount
+		}
+		return out[i].Category < out[j].Category
+	})
+	if n > 0 && len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+// money renders an amount as a JSON number in currency
+func money(m models.Money, currency string) json.RawMessage {
+	return json.RawMessage(m.Format(currency))
+}
+
+func totalsJSON(t ReportTotals, currency string) gin.H {
+	return gin.H{
+		"expense": money(t.Expense, currency),
+		"income":  money(t.Income, currency),
+		"net":     money(t.Net(), currency),
+		"count":   t.Count,
+	}
+}
+
+// JSON renders the summary for the API
+func (s *Summary) JSON() gin.H {
+	groups := make([]gin.H, 0, len(s.Groups))
+	for _, g := range s.Groups {
+		item := gin.H{"key": g.Key, "totals": totalsJSON(g.Totals, s.BaseCurrency)}
+		if isTimeGrouping(s.GroupBy) {
+			item["from"], item["to"] = g.From, g.To
+			breakdown := []gin.H{}
+			for _, c := range g.topCategories(maxBreakdownCategories) {
+				breakdown = append(breakdown, gin.H{"category": c.Category, "expense": money(c.Amount, s.BaseCurrency)})
+			}
+			item["categories"] = breakdown
+		}
+		groups = append(groups, item)
+	}
+	return gin.H{
+		"group_by":        s.GroupBy,
+		"base_currency":   s.BaseCurrency,
+		"month_start_day": s.MonthStartDay,
+		"totals":          totalsJSON(s.Totals, s.BaseCurrency),
+		"groups":          groups,
+		"unconverted":     s.Unconverted,
+		"missing_rates":   s.MissingRates,
+	}
+}
+
+// loadSummary reads the part of the user's ledger selected by filter and
+// summarises it in their base currency
+func loadSummary(settings models.UserSettings, filter TransactionFilter, groupBy string) (*Summary, error) {
+	scope, err := filter.scope()
+	if err != nil {
+		return nil, err
+	}
+	var rows []models.Transaction
+	if err := database.DB.Scopes(ledgerScope(settings.UserID), scope).
+		Select("date", "category", "main_category", "account", "member", "type", "currency", "amount_minor").
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	currencies := map[string]bool{}
+	list := []string{}
+	for _, t := range rows {
+		if cur := models.NormalizeCurrency(t.Currency); !currencies[cur] {
+			currencies[cur] = true
+			list = append(list, cur)
+		}
+	}
+	converter, err := newCurrencyConverter(database.DB, settings.BaseCurrency, list)
+	if err != nil {
+		return nil, err
+	}
+	return summarize(rows, converter, groupBy, settings.MonthStartDay), nil
+}
+
+// parseReportRange reads from/to, or a relative window such as this_month
+// resolved on the user's calendar
+func parseReportRange(c *gin.Context, settings models.UserSettings) (string, string, error) {
+	if window := c.Query("window"); window != "" {
+		if c.Query("from") != "" || c.Query("to") != "" {
+			return "", "", fmt.Errorf("%w: use either window or from/to", errInvalidQuery)
+		}
+		from, to, err := resolveDateWindow(window, settings.Now(), settings.MonthStartDay)
+		if err != nil {
+			return "", "", fmt.Errorf("%w: %v", errInvalidQuery, err)
+		}
+		return from, to, nil
+	}
+	from, err := parseQueryDate("from", c.Query("from"))
+	if err != nil {
+		return "", "", err
+	}
+	to, err := parseQueryDate("to", c.Query("to"))
+	if err != nil {
+		return "", "", err
+	}
+	if from != "" && to != "" && from > to {
+		return "", "", fmt.Errorf("%w: from is after to", errInvalidQuery)
+	}
+	return from, to, nil
+}
+
+// GetSummary aggregates the ledger in the user's base currency.
+// Query: from, to (or window), groupBy (month by default).
+func GetSummary(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	groupBy := c.DefaultQuery("groupBy", GroupByMonth)
+	if !validGroupBy(groupBy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "groupBy must be one of year, month, week, category, mainCategory, account or member"})
+		return
+	}
+	settings, err := loadUserSettings(userID)
+	if err != nil {
+		utils.LogError("GetSummary: loadUserSettings failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load settings: " + err.Error()})
+		return
+	}
+	from, to, err := parseReportRange(c, settings)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	summary, err := loadSummary(settings, TransactionFilter{From: from, To: to}, groupBy)
+	if err != nil {
+		utils.LogError("GetSummary: loadSummary failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build summary: " + err.Error()})
+		return
+	}
+
+	body := summary.JSON()
+	body["from"], body["to"] = from, to
+	c.JSON(http.StatusOK, body)
+}
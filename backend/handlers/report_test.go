@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"ledger-lens/backend/models"
+)
+
+func TestSummarize(t *testing.T) {
+	converter := &currencyConverter{
+		base:  "TWD",
+		rates: map[[2]string][]datedRate{{"USD", "TWD"}: {{"20240101", big.NewRat(30, 1)}}},
+	}
+	rows := []models.Transaction{
+		{Date: "20240105", Type: "支", Category: "早餐", Amount: 100, Currency: "TWD"},
+		{Date: "20240131", Type: "支", Category: "午餐", Amount: 250, Currency: "TWD"},
+		{Date: "20240201", Type: "支", Category: "早餐", Amount: 1000, Currency: "USD"}, // 10.00 USD
+		{Date: "20240210", Type: "收", Category: "薪水", Amount: 50000, Currency: "TWD"},
+		{Date: "20240212", Type: "轉", Category: "轉帳", Amount: 999, Currency: "TWD"},
+		{Date: "20240215", Type: "支", Category: "早餐", Amount: 500, Currency: "EUR"},
+	}
+
+	s := summarize(rows, converter, GroupByMonth, 1)
+	if s.Unconverted != 1 || len(s.MissingRates) != 1 || s.MissingRates[0] != "EUR" {
+		t.Fatalf("unconverted = %d %v, want 1 [EUR]", s.Unconverted, s.MissingRates)
+	}
+	if s.Totals.Expense != 650 || s.Totals.Income != 50000 || s.Totals.Count != 5 {
+		t.Errorf("totals = %+v", s.Totals)
+	}
+	if len(s.Groups) != 2 || s.Groups[0].Key != "2024-01" || s.Groups[1].Key != "2024-02" {
+		t.Fatalf("groups = %+v", s.Groups)
+	}
+	feb := s.Groups[1]
+	if feb.From != "20240201" || feb.To != "20240229" {
+		t.Errorf("february bounds = %s..%s", feb.From, feb.To)
+	}
+	if feb.Totals.Net() != 49700 {
+		t.Errorf("february net = %d, want 49700", feb.Totals.Net())
+	}
+	if top := s.Groups[0].topCategories(1); len(top) != 1 || top[0].Category != "午餐" {
+		t.Errorf("january top category = %+v", top)
+	}
+
+	s = summarize(rows, converter, GroupByMonth, 25)
+	if len(s.Groups) != 2 || s.Groups[0].Key != "2023-12" || s.Groups[1].From != "20240125" || s.Groups[1].To != "20240224" {
+		t.Errorf("fiscal months = %+v %+v", s.Groups[0], s.Groups[1])
+	}
+
+	s = summarize(rows, converter, GroupByCategory, 1)
+	if s.Groups[0].Key != "早餐" || s.Groups[0].Totals.Expense != 400 {
+		t.Errorf("largest category = %+v", s.Groups[0])
+	}
+}
+
+func TestPeriodOfWeek(t *testing.T) {
+	p := periodOf(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), GroupByWeek, 1) // a Monday
+	if p.Key != "2024-W01" || p.From != "20240101" || p.To != "20240107" {
+		t.Errorf("week of 2024-01-01 = %+v", p)
+	}
+	p = periodOf(time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC), GroupByWeek, 1)
+	if p.Key != "2025-W01" || p.From != "20241230" {
+		t.Errorf("week of 2024-12-31 = %+v", p)
+	}
+}
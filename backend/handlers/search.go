@@ -0,0 +1,579 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"ledger-lens/backend/models"
+	"ledger-lens/backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The search language combines terms with implicit AND:
+//
+//	category:餐飲 amount>500 date:2024-01..2024-03 -tag:公司 "午餐"
+//
+// A term is free text, a "quoted phrase" or field<op>value, where op is one of
+// : = > >= < <=. A leading minus negates a term, OR joins alternatives and
+// parentheses group. Dates may be a day, a month (2024-01) or a year (2024),
+// and field:a..b takes an inclusive range with either end left open.
+
+// QueryError reports where a search query stopped making sense
+type QueryError struct {
+	Pos int    `json:"position"` // 0-based rune offset into the query
+	Msg string `json:"message"`
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("query error at position %d: %s", e.Pos, e.Msg)
+}
+
+// QueryNode is a node of a parsed search query
+type QueryNode interface {
+	queryNode()
+}
+
+// AndNode matches when every child matches
+type AndNode struct{ Children []QueryNode }
+
+// OrNode matches when any child matches
+type OrNode struct{ Children []QueryNode }
+
+// NotNode inverts its child
+type NotNode struct{ Child QueryNode }
+
+// TextNode matches free text against the descriptive fields
+type TextNode struct{ Text string }
+
+// FieldNode compares a text field. Exact fields match whole values,
+// the others (note, tag) match substrings.
+type FieldNode struct {
+	Field string // canonical name, e.g. "category"
+	Value string
+}
+
+// AmountNode compares the amount, in units of 10^-MaxCurrencyExponent of each row's currency
+type AmountNode struct {
+	Op    string // = > >= < <=
+	Milli int64
+}
+
+// DateNode matches dates in an inclusive range; an empty bound is open
+type DateNode struct{ From, To string }
+
+func (AndNode) queryNode()    {}
+func (OrNode) queryNode()     {}
+func (NotNode) queryNode()    {}
+func (TextNode) queryNode()   {}
+func (FieldNode) queryNode()  {}
+func (AmountNode) queryNode() {}
+func (DateNode) queryNode()   {}
+
+// queryFields maps field names, including Chinese column names, to canonical fields
+var queryFields = map[string]string{
+	"category":     "category",
+	"cat":          "category",
+	"類別":           "category",
+	"main":         "mainCategory",
+	"maincategory": "mainCategory",
+	"大類別":          "mainCategory",
+	"account":      "account",
+	"acct":         "account",
+	"帳戶":           "account",
+	"member":       "member",
+	"成員":           "member",
+	"tag":          "tag",
+	"tags":         "tag",
+	"標籤":           "tag",
+	"note":         "note",
+	"備註":           "note",
+	"type":         "type",
+	"收支":           "type",
+	"currency":     "currency",
+	"貨幣":           "currency",
+	"amount":       "amount",
+	"金額":           "amount",
+	"date":         "date",
+	"日期":           "date",
+}
+
+// typeAliases lets type: take English names
+var typeAliases = map[string]string{
+	"expense":  "支",
+	"income":   "收",
+	"transfer": "轉",
+}
+
+type tokenKind int
+
+const (
+	tokenText tokenKind = iota
+	tokenPhrase
+	tokenField
+	tokenNot
+	tokenOr
+	tokenOpen
+	tokenClose
+)
+
+type queryToken struct {
+	kind  tokenKind
+	pos   int
+	text  string // free text, or the value of a field term
+	field string // canonical field of a field term
+	op    string
+	// valuePos is where the value of a field term starts
+	valuePos int
+}
+
+// ParseQuery parses a search query. An empty query yields a nil node.
+func ParseQuery(query string) (QueryNode, error) {
+	tokens, err := lexQuery([]rune(utils.HalfWidth(query)))
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens, end: len([]rune(query))}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.i < len(p.tokens) {
+		return nil, &QueryError{Pos: p.tokens[p.i].pos, Msg: "unexpected )"}
+	}
+	return node, nil
+}
+
+func isQueryDelimiter(r rune) bool {
+	return unicode.IsSpace(r) || r == '(' || r == ')'
+}
+
+func lexQuery(rs []rune) ([]queryToken, error) {
+	var tokens []queryToken
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, queryToken{kind: tokenOpen, pos: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, queryToken{kind: tokenClose, pos: i})
+			i++
+		case r == '-' && i+1 < len(rs) && !isQueryDelimiter(rs[i+1]) && (rs[i+1] < '0' || rs[i+1] > '9'):
+			tokens = append(tokens, queryToken{kind: tokenNot, pos: i})
+			i++
+		case r == '"':
+			text, next, err := lexPhrase(rs, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, queryToken{kind: tokenPhrase, pos: i, text: text})
+			i = next
+		default:
+			start := i
+			for i < len(rs) && !isQueryDelimiter(rs[i]) && !strings.ContainsRune(":=<>\"", rs[i]) {
+				i++
+			}
+			name := string(rs[start:i])
+			field, known := queryFields[strings.ToLower(name)]
+			if known && i < len(rs) && strings.ContainsRune(":=<>", rs[i]) {
+				tok := queryToken{kind: tokenField, pos: start, field: field}
+				opStart := i
+				for i < len(rs) && strings.ContainsRune(":=<>", rs[i]) {
+					i++
+				}
+				tok.op = string(rs[opStart:i])
+				switch tok.op {
+				case ":", "=", ">", ">=", "<", "<=":
+				default:
+					return nil, &QueryError{Pos: opStart, Msg: fmt.Sprintf("unknown operator %q", tok.op)}
+				}
+				valueStart := i
+				tok.valuePos = valueStart
+				if i < len(rs) && rs[i] == '"' {
+					text, next, err := lexPhrase(rs, i)
+					if err != nil {
+						return nil, err
+					}
+					tok.text, i = text, next
+				} else {
+					for i < len(rs) && !isQueryDelimiter(rs[i]) {
+						i++
+					}
+					tok.text = string(rs[valueStart:i])
+				}
+				if tok.text == "" {
+					return nil, &QueryError{Pos: valueStart, Msg: fmt.Sprintf("missing value after %s%s", name, tok.op)}
+				}
+				tokens = append(tokens, tok)
+				continue
+			}
+			if !known && name != "" && i < len(rs) && strings.ContainsRune(":=<>", rs[i]) && isWord(name) {
+				return nil, &QueryError{Pos: start, Msg: fmt.Sprintf("unknown field %q", name)}
+			}
+			// Not a field term: the whole run up to the next delimiter is text
+			for i < len(rs) && !isQueryDelimiter(rs[i]) {
+				i++
+			}
+			word := string(rs[start:i])
+			if word == "OR" {
+				tokens = append(tokens, queryToken{kind: tokenOr, pos: start})
+			} else {
+				tokens = append(tokens, queryToken{kind: tokenText, pos: start, text: word})
+			}
+		}
+	}
+	return tokens, nil
+}
+
+// lexPhrase reads a quoted string starting at rs[i], honouring \" escapes
+func lexPhrase(rs []rune, i int) (string, int, error) {
+	var b strings.Builder
+	for j := i + 1; j < len(rs); j++ {
+		switch {
+		case rs[j] == '\\' && j+1 < len(rs):
+			j++
+			b.WriteRune(rs[j])
+		case rs[j] == '"':
+			return b.String(), j + 1, nil
+		default:
+			b.WriteRune(rs[j])
+		}
+	}
+	return "", 0, &QueryError{Pos: i, Msg: "unterminated quote"}
+}
+
+// quoteQuery quotes a value so ParseQuery reads it as a single phrase
+func quoteQuery(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+type queryParser struct {
+	tokens []queryToken
+	i      int
+	end    int // position reported for errors at the end of input
+}
+
+func (p *queryParser) peek() *queryToken {
+	if p.i < len(p.tokens) {
+		return &p.tokens[p.i]
+	}
+	return nil
+}
+
+// parseOr: and ("OR" and)*
+func (p *queryParser) parseOr() (QueryNode, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	children := []QueryNode{first}
+	for t := p.peek(); t != nil && t.kind == tokenOr; t = p.peek() {
+		p.i++
+		next, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, next)
+	}
+	if len(children) == 1 {
+		return first, nil
+	}
+	return OrNode{Children: children}, nil
+}
+
+// parseAnd: unary+
+func (p *queryParser) parseAnd() (QueryNode, error) {
+	var children []QueryNode
+	for t := p.peek(); t != nil && t.kind != tokenOr && t.kind != tokenClose; t = p.peek() {
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, node)
+	}
+	if len(children) == 0 {
+		pos := p.end
+		if t := p.peek(); t != nil {
+			pos = t.pos
+		}
+		return nil, &QueryError{Pos: pos, Msg: "expected a search term"}
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return AndNode{Children: children}, nil
+}
+
+// parseUnary: "-" unary | "(" or ")" | term
+func (p *queryParser) parseUnary() (QueryNode, error) {
+	t := p.peek()
+	p.i++
+	switch t.kind {
+	case tokenNot:
+		if next := p.peek(); next == nil || next.kind == tokenOr || next.kind == tokenClose {
+			return nil, &QueryError{Pos: t.pos, Msg: "nothing to negate after -"}
+		}
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return NotNode{Child: child}, nil
+	case tokenOpen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.peek(); closing == nil || closing.kind != tokenClose {
+			return nil, &QueryError{Pos: t.pos, Msg: "unclosed ("}
+		}
+		p.i++
+		return node, nil
+	case tokenText, tokenPhrase:
+		return TextNode{Text: t.text}, nil
+	case tokenField:
+		return fieldNode(t)
+	}
+	return nil, &QueryError{Pos: t.pos, Msg: "unexpected token"}
+}
+
+// fieldNode types the value of a field term
+func fieldNode(t *queryToken) (QueryNode, error) {
+	valuePos := t.valuePos
+	switch t.field {
+	case "amount":
+		return amountNode(t, valuePos)
+	case "date":
+		return dateNode(t, valuePos)
+	}
+	if t.op != ":" && t.op != "=" {
+		return nil, &QueryError{Pos: t.pos, Msg: fmt.Sprintf("%s only supports : and =", t.field)}
+	}
+	value := t.text
+	switch t.field {
+	case "type":
+		if alias, ok := typeAliases[strings.ToLower(value)]; ok {
+			value = alias
+		}
+		if value != "支" && value != "收" && value != "轉" {
+			return nil, &QueryError{Pos: valuePos, Msg: fmt.Sprintf("type must be 支, 收 or 轉, got %q", t.text)}
+		}
+	case "currency":
+		value = models.NormalizeCurrency(value)
+	}
+	return FieldNode{Field: t.field, Value: value}, nil
+}
+
+func amountNode(t *queryToken, pos int) (QueryNode, error) {
+	parse := func(s string) (int64, error) {
+		v, err := models.ParseScaled(s, models.MaxCurrencyExponent)
+		if err != nil {
+			return 0, &QueryError{Pos: pos, Msg: fmt.Sprintf("amount %q is not a number", s)}
+		}
+		return v, nil
+	}
+
+	if lo, hi, ok := strings.Cut(t.text, ".."); ok {
+		if t.op != ":" {
+			return nil, &QueryError{Pos: pos, Msg: "ranges are written amount:min..max"}
+		}
+		var children []QueryNode
+		if lo != "" {
+			v, err := parse(lo)
+			if err != nil {
+				return nil, err
+			}
+			children = append(children, AmountNode{Op: ">=", Milli: v})
+		}
+		if hi != "" {
+			v, err := parse(hi)
+			if err != nil {
+				return nil, err
+			}
+			children = append(children, AmountNode{Op: "<=", Milli: v})
+		}
+		if len(children) == 0 {
+			return nil, &QueryError{Pos: pos, Msg: "empty amount range"}
+		}
+		if len(children) == 1 {
+			return children[0], nil
+		}
+		return AndNode{Children: children}, nil
+	}
+
+	v, err := parse(t.text)
+	if err != nil {
+		return nil, err
+	}
+	op := t.op
+	if op == ":" {
+		op = "="
+	}
+	return AmountNode{Op: op, Milli: v}, nil
+}
+
+func dateNode(t *queryToken, pos int) (QueryNode, error) {
+	lo, hi, isRange := strings.Cut(t.text, "..")
+	if isRange {
+		if t.op != ":" {
+			return nil, &QueryError{Pos: pos, Msg: "ranges are written date:from..to"}
+		}
+		var n DateNode
+		if lo != "" {
+			start, _, err := datePeriod(lo, pos)
+			if err != nil {
+				return nil, err
+			}
+			n.From = start.Format(utils.StoredDateLayout)
+		}
+		if hi != "" {
+			_, end, err := datePeriod(hi, pos)
+			if err != nil {
+				return nil, err
+			}
+			n.To = end.Format(utils.StoredDateLayout)
+		}
+		if n.From == "" && n.To == "" {
+			return nil, &QueryError{Pos: pos, Msg: "empty date range"}
+		}
+		return n, nil
+	}
+
+	start, end, err := datePeriod(t.text, pos)
+	if err != nil {
+		return nil, err
+	}
+	day := func(d time.Time) string { return d.Format(utils.StoredDateLayout) }
+	switch t.op {
+	case ">":
+		return DateNode{From: day(end.AddDate(0, 0, 1))}, nil
+	case ">=":
+		return DateNode{From: day(start)}, nil
+	case "<":
+		return DateNode{To: day(start.AddDate(0, 0, -1))}, nil
+	case "<=":
+		return DateNode{To: day(end)}, nil
+	}
+	return DateNode{From: day(start), To: day(end)}, nil
+}
+
+// datePeriod reads a day, a month (2024-01, 2024/1) or a year (2024) and
+// returns its first and last day
+func datePeriod(s string, pos int) (time.Time, time.Time, error) {
+	if len(s) == 4 && isAllDigits(s) {
+		y, _ := strconv.Atoi(s)
+		start := time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(1, 0, -1), nil
+	}
+	if y, m, ok := strings.Cut(strings.NewReplacer("/", "-", ".", "-").Replace(s), "-"); ok && len(y) == 4 && isAllDigits(y) && isAllDigits(m) && len(m) <= 2 && !strings.Contains(m, "-") {
+		year, _ := strconv.Atoi(y)
+		month, _ := strconv.Atoi(m)
+		if month >= 1 && month <= 12 {
+			start := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+			return start, start.AddDate(0, 1, -1), nil
+		}
+	}
+	d, err := utils.NormalizeDate(s)
+	if err != nil {
+		return time.Time{}, time.Time{}, &QueryError{Pos: pos, Msg: err.Error()}
+	}
+	return d, d, nil
+}
+
+func isAllDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// isWord reports whether s is made of letters only, like a field name
+func isWord(s string) bool {
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// textColumns are searched by free text
+var textColumns = []string{"category", "main_category", "note", "tags", "account", "member"}
+
+// compileQuery turns a query into a SQL condition over the transactions table
+func compileQuery(node QueryNode) (string, []interface{}) {
+	switch n := node.(type) {
+	case AndNode:
+		return compileJoined(n.Children, " AND ")
+	case OrNode:
+		return compileJoined(n.Children, " OR ")
+	case NotNode:
+		sql, args := compileQuery(n.Child)
+		return "NOT (" + sql + ")", args
+	case TextNode:
+		like := "%" + escapeLike(n.Text) + "%"
+		parts := make([]string, len(textColumns))
+		args := make([]interface{}, len(textColumns))
+		for i, col := range textColumns {
+			parts[i], args[i] = col+" ILIKE ?", like
+		}
+		return strings.Join(parts, " OR "), args
+	case FieldNode:
+		switch n.Field {
+		case "category":
+			// category: also matches the main category, which is how people usually think of 餐飲
+			return "category = ? OR main_category = ?", []interface{}{n.Value, n.Value}
+		case "mainCategory":
+			return "main_category = ?", []interface{}{n.Value}
+		case "tag":
+			return tagCondition(n.Value)
+		case "note":
+			return "note ILIKE ?", []interface{}{"%" + escapeLike(n.Value) + "%"}
+		}
+		return n.Field + " = ?", []interface{}{n.Value}
+	case AmountNode:
+		return amountMilliSQL + " " + n.Op + " ?", []interface{}{n.Milli}
+	case DateNode:
+		switch {
+		case n.From != "" && n.To != "":
+			return "date BETWEEN ? AND ?", []interface{}{n.From, n.To}
+		case n.From != "":
+			return "date >= ?", []interface{}{n.From}
+		default:
+			return "date <= ?", []interface{}{n.To}
+		}
+	}
+	return "TRUE", nil
+}
+
+func compileJoined(children []QueryNode, joiner string) (string, []interface{}) {
+	parts := make([]string, len(children))
+	var args []interface{}
+	for i, child := range children {
+		sql, childArgs := compileQuery(child)
+		parts[i] = "(" + sql + ")"
+		args = append(args, childArgs...)
+	}
+	return strings.Join(parts, joiner), args
+}
+
+// queryErrorResponse adds the error position for query errors
+func queryErrorResponse(err error) gin.H {
+	body := gin.H{"error": err.Error()}
+	var qe *QueryError
+	if errors.As(err, &qe) {
+		body["position"] = qe.Pos
+	}
+	return body
+}
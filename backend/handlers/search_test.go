@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	node, err := ParseQuery(`category:餐飲 amount>500 date:2024-01..2024-03 -tag:公司 "午餐"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := AndNode{Children: []QueryNode{
+		FieldNode{Field: "category", Value: "餐飲"},
+		AmountNode{Op: ">", Milli: 500000},
+		DateNode{From: "20240101", To: "20240331"},
+		NotNode{Child: FieldNode{Field: "tag", Value: "公司"}},
+		TextNode{Text: "午餐"},
+	}}
+	if !reflect.DeepEqual(node, want) {
+		t.Errorf("Unexpected AST:\n got %#v\nwant %#v", node, want)
+	}
+}
+
+func TestParseQueryOperators(t *testing.T) {
+	cases := []struct {
+		query string
+		want  QueryNode
+	}{
+		{"", nil},
+		{"date>2024-02", DateNode{From: "20240301"}},
+		{"date<=2024", DateNode{To: "20241231"}},
+		{"日期:113/02/29", DateNode{From: "20240229", To: "20240229"}},
+		{"amount:100..", AmountNode{Op: ">=", Milli: 100000}},
+		{"type:expense", FieldNode{Field: "type", Value: "支"}},
+		{"(cat:午餐 OR cat:晚餐) -5", AndNode{Children: []QueryNode{
+			OrNode{Children: []QueryNode{FieldNode{Field: "category", Value: "午餐"}, FieldNode{Field: "category", Value: "晚餐"}}},
+			TextNode{Text: "-5"},
+		}}},
+		{`note:"便當 加蛋"`, FieldNode{Field: "note", Value: "便當 加蛋"}},
+	}
+	for _, tc := range cases {
+		got, err := ParseQuery(tc.query)
+		if err != nil {
+			t.Errorf("ParseQuery(%q) failed: %v", tc.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ParseQuery(%q) = %#v, want %#v", tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	cases := []struct {
+		query string
+		pos   int
+	}{
+		{`note:"午餐`, 5},
+		{"amount>abc", 7},
+		{"(cat:午餐", 0},
+		{"午餐 )", 3},
+		{"catgory:午餐", 0},
+		{"type:借", 5},
+		{"午餐 OR", 5},
+		{"date:2024-13-01", 5},
+	}
+	for _, tc := range cases {
+		_, err := ParseQuery(tc.query)
+		var qe *QueryError
+		if !errors.As(err, &qe) {
+			t.Errorf("ParseQuery(%q): expected QueryError, got %v", tc.query, err)
+			continue
+		}
+		if qe.Pos != tc.pos {
+			t.Errorf("ParseQuery(%q): expected position %d, got %d (%s)", tc.query, tc.pos, qe.Pos, qe.Msg)
+		}
+	}
+}
+
+func TestCompileQuery(t *testing.T) {
+	node, err := ParseQuery("-tag:公司 date:2024")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sql, args := compileQuery(node)
+	if sql != "(NOT (lower(?) = ANY(regexp_split_to_array(lower(tags), '"+tagSeparators+"')))) AND (date BETWEEN ? AND ?)" {
+		t.Errorf("Unexpected SQL: %s", sql)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"公司", "20240101", "20241231"}) {
+		t.Errorf("Unexpected args: %v", args)
+	}
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"ledger-lens/backend/database"
+	"ledger-lens/backend/models"
+	"ledger-lens/backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/text/language"
+	"gorm.io/gorm"
+)
+
+var currencyCodePattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// SettingsInput is a partial update; omitted fields keep their value
+type SettingsInput struct {
+	BaseCurrency  *string `json:"base_currency"`
+	MonthStartDay *int    `json:"month_start_day"`
+	TimeZone      *string `json:"time_zone"`
+	Locale        *string `json:"locale"`
+
+	BudgetAlerts      *string `json:"budget_alerts"`
+	BudgetWarnPercent *int    `json:"budget_warn_percent"`
+}
+
+// apply validates the input and copies it onto s
+func (in *SettingsInput) apply(s *models.UserSettings) error {
+	if in.BaseCurrency != nil {
+		currency := models.NormalizeCurrency(*in.BaseCurrency)
+		if !currencyCodePattern.MatchString(currency) {
+			return fmt.Errorf("base_currency %q is not an ISO 4217 code", *in.BaseCurrency)
+		}
+		s.BaseCurrency = currency
+	}
+	if in.MonthStartDay != nil {
+		if *in.MonthStartDay < 1 || *in.MonthStartDay > models.MaxMonthStartDay {
+			return fmt.Errorf("month_start_day must be between 1 and %d", models.MaxMonthStartDay)
+		}
+		s.MonthStartDay = *in.MonthStartDay
+	}
+	if in.TimeZone != nil {
+		zone := strings.TrimSpace(*in.TimeZone)
+		if _, err := time.LoadLocation(zone); err != nil || zone == "" || zone == "Local" {
+			return fmt.Errorf("time_zone %q is not an IANA time zone", *in.TimeZone)
+		}
+		s.TimeZone = zone
+	}
+	if in.Locale != nil {
+		tag, err := language.Parse(strings.TrimSpace(*in.Locale))
+		if err != nil {
+			return fmt.Errorf("locale %q is not a BCP 47 language tag", *in.Locale)
+		}
+		s.Locale = tag.String()
+	}
+	if in.BudgetAlerts != nil {
+		switch *in.BudgetAlerts {
+		case models.BudgetAlertsAll, models.BudgetAlertsOver, models.BudgetAlertsOff:
+			s.BudgetAlerts = *in.BudgetAlerts
+		default:
+			return fmt.Errorf("budget_alerts must be %s, %s or %s", models.BudgetAlertsAll, models.BudgetAlertsOver, models.BudgetAlertsOff)
+		}
+	}
+	if in.BudgetWarnPercent != nil {
+		if *in.BudgetWarnPercent < 1 || *in.BudgetWarnPercent > 99 {
+			return errors.New("budget_warn_percent must be between 1 and 99")
+		}
+		s.BudgetWarnPercent = *in.BudgetWarnPercent
+	}
+	return nil
+}
+
+// loadUserSettings returns the user's settings, or the defaults if none were saved
+func loadUserSettings(userID uuid.UUID) (models.UserSettings, error) {
+	settings := models.DefaultUserSettings(userID)
+	err := database.DB.Where("user_id = ?", userID).First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.DefaultUserSettings(userID), nil
+	}
+	return settings, err
+}
+
+// GetSettings returns the user's preferences
+func GetSettings(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	settings, err := loadUserSettings(userID)
+	if err != nil {
+		utils.LogError("GetSettings: DB First failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load settings: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"settings": settings})
+}
+
+// UpdateSettings changes the fields present in the request body
+func UpdateSettings(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var input SettingsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, err := loadUserSettings(userID)
+	if err != nil {
+		utils.LogError("UpdateSettings: DB First failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load settings: " + err.Error()})
+		return
+	}
+	if err := input.apply(&settings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.DB.Save(&settings).Error; err != nil {
+		utils.LogError("UpdateSettings: DB Save failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save settings: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"settings": settings})
+}
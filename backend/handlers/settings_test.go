@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"testing"
+
+	"ledger-lens/backend/models"
+
+	"github.com/google/uuid"
+)
+
+func TestSettingsInputApply(t *testing.T) {
+	str := func(s string) *string { return &s }
+	day := func(d int) *int { return &d }
+
+	s := models.DefaultUserSettings(uuid.New())
+	in := SettingsInput{BaseCurrency: str("usd"), MonthStartDay: day(25), TimeZone: str(" Europe/Berlin "), Locale: str("en-gb")}
+	if err := in.apply(&s); err != nil {
+		t.Fatal(err)
+	}
+	if s.BaseCurrency != "USD" || s.MonthStartDay != 25 || s.TimeZone != "Europe/Berlin" || s.Locale != "en-GB" {
+		t.Errorf("applied settings = %+v", s)
+	}
+	if s.Location().String() != "Europe/Berlin" {
+		t.Errorf("Location() = %s", s.Location())
+	}
+
+	for _, bad := range []SettingsInput{
+		{BaseCurrency: str("dollars")},
+		{MonthStartDay: day(0)},
+		{MonthStartDay: day(31)},
+		{TimeZone: str("Mars/Olympus")},
+		{TimeZone: str("Local")},
+		{Locale: str("not a locale")},
+	} {
+		s := models.DefaultUserSettings(uuid.New())
+		if err := bad.apply(&s); err == nil {
+			t.Errorf("apply(%+v) accepted", bad)
+		}
+	}
+}
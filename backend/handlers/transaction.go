@@ -1,51 +1,125 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"mime/multipart"
 	"net/http"
 
 	"ledger-lens/backend/database"
 	"ledger-lens/backend/models"
-	"ledger-lens/backend/storage"
 	"ledger-lens/backend/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type TransactionInput struct {
-	Transactions []map[string]interface{} `json:"transactions" binding:"required"`
+	Transactions []models.Transaction `json:"transactions" binding:"required"`
+	Mode         string               `json:"mode"` // merge (default) or overwrite
 }
 
-// GetTransactions retrieves the user's transactions from JSON file
+// GetTransactions retrieves the user's transactions. Filters are described
+// at parseTransactionFilter; sort takes keys such as "-date,amount"; limit
+// (default 100) and cursor page through the result. total counts
+// all matching rows regardless of paging.
 func GetTransactions(c *gin.Context) {
 	userID := c.MustGet("user_id").(uuid.UUID)
+	query := c.Request.URL.Query()
 
-	var userTransaction models.UserTransaction
-	result := database.DB.Where("user_id = ?", userID).First(&userTransaction)
+	filter, err := parseTransactionFilter(query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	listTransactions(c, userID, filter, query.Get("sort"), gin.H{})
+}
 
-	if result.Error != nil {
-		// No transactions found, return empty array
-		c.JSON(http.StatusOK, gin.H{"transactions": []interface{}{}})
+// listTransactions responds with the user's transactions matching filter,
+// paged by the limit and cursor query parameters. extra is merged into the response.
+func listTransactions(c *gin.Context, userID uuid.UUID, filter TransactionFilter, sort string, extra gin.H) {
+	query := c.Request.URL.Query()
+	filterScope, err := filter.scope()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, queryErrorResponse(err))
+		return
+	}
+	keys, err := parseSort(sort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	cursor := query.Get("cursor")
+	limit, err := parsePageSize(query.Get("limit"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	base := database.DB.Model(&models.Transaction{}).Scopes(ledgerScope(userID), filterScope)
 
-	// 從檔案讀取
-	if userTransaction.FilePath == "" {
-		c.JSON(http.StatusOK, gin.H{"transactions": []interface{}{}})
+	var total int64
+	if err := base.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		utils.LogError("listTransactions: DB Count failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load transactions: " + err.Error()})
 		return
 	}
 
-	transactions, err := storage.ReadTransactionFile(userTransaction.FilePath)
+	page := base.Session(&gorm.Session{}).Order(orderClause(keys))
+	if cursor != "" {
+		after, err := afterCursor(keys, cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		page = page.Scopes(after)
+	}
+	page = page.Limit(limit + 1) // one extra row tells whether there is a next page
+
+	transactions := []models.Transaction{}
+	if err := page.Find(&transactions).Error; err != nil {
+		utils.LogError("listTransactions: DB Find failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load transactions: " + err.Error()})
+		return
+	}
+	nextCursor := ""
+	if len(transactions) > limit {
+		transactions = transactions[:limit]
+		nextCursor = encodeCursor(keys, transactions[limit-1])
+	}
+
+	settings, err := loadUserSettings(userID)
+	if err != nil {
+		utils.LogError("listTransactions: load settings failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load settings: " + err.Error()})
+		return
+	}
+	missing, err := convertTransactions(database.DB, settings.BaseCurrency, transactions)
 	if err != nil {
-		utils.LogError("GetTransactions: ReadTransactionFile failed", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read transactions file: " + err.Error()})
+		utils.LogError("listTransactions: currency conversion failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load exchange rates: " + err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"transactions": transactions})
+	extra["transactions"] = transactions
+	extra["total"] = total
+	extra["next_cursor"] = nextCursor // empty on the last page
+	extra["base_currency"] = settings.BaseCurrency
+	extra["missing_rates"] = missing // currencies left without baseAmount
+	c.JSON(http.StatusOK, extra)
 }
 
-// SaveTransactions saves the user's transactions to JSON file
+// ledgerScope limits a query to the user's confirmed transactions,
+// leaving out probable duplicates that are still awaiting review
+func ledgerScope(userID uuid.UUID) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("user_id = ? AND duplicate_of_id IS NULL", userID)
+	}
+}
+
+// SaveTransactions imports the posted transactions into the user's ledger
 func SaveTransactions(c *gin.Context) {
 	userID := c.MustGet("user_id").(uuid.UUID)
 
@@ -55,38 +129,156 @@ func SaveTransactions(c *gin.Context) {
 		return
 	}
 
-	// 儲存到檔案
-	filePath, err := storage.SaveTransactionFile(userID.String(), input.Transactions)
+	mode, err := parseImportMode(input.Mode)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rows, report := validateTransactions(input.Transactions)
+
+	result, err := importTransactions(userID, rows, report, mode)
+	if errors.Is(err, errOverwriteFailedRows) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "report": report})
+		return
+	}
 	if err != nil {
-		utils.LogError("SaveTransactions: SaveTransactionFile failed", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save transactions file: " + err.Error()})
+		utils.LogError("SaveTransactions: importTransactions failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save transactions: " + err.Error()})
 		return
 	}
 
-	// Upsert: Update if exists, create if not
-	var userTransaction models.UserTransaction
-	result := database.DB.Where("user_id = ?", userID).First(&userTransaction)
+	c.JSON(http.StatusOK, gin.H{"message": "Transactions saved successfully", "result": result, "report": report})
+}
 
-	if result.Error != nil {
-		// Create new record
-		userTransaction = models.UserTransaction{
-			UserID:   userID,
-			FilePath: filePath,
+// validateTransactions applies the CSV row checks to JSON-posted rows,
+// dropping rejected ones. Line is the 1-based position in the array.
+func validateTransactions(transactions []models.Transaction) ([]models.Transaction, *ImportReport) {
+	report := newImportReport()
+	report.TotalRows = len(transactions)
+	valid := make([]models.Transaction, 0, len(transactions))
+	for i, t := range transactions {
+		failed := false
+		t.DateRaw = "" // always re-derived from Date
+		diags := validateTransaction(&t)
+		if t.RoundedFrom != "" {
+			diags = append(diags, ImportDiagnostic{
+				Field:    "amount",
+				Code:     DiagRoundedAmount,
+				Reason:   fmt.Sprintf("amount %q rounded to %s %s", t.RoundedFrom, t.Amount.Format(t.Currency), t.Currency),
+				Severity: SeverityWarning,
+			})
 		}
-		if err := database.DB.Create(&userTransaction).Error; err != nil {
-			utils.LogError("SaveTransactions: DB Create failed", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save transaction record: " + err.Error()})
-			return
+		for _, d := range diags {
+			d.Line = i + 1
+			report.add(d)
+			failed = failed || d.Severity == SeverityError
 		}
-	} else {
-		// Update existing record
-		userTransaction.FilePath = filePath
-		if err := database.DB.Save(&userTransaction).Error; err != nil {
-			utils.LogError("SaveTransactions: DB Save failed", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update transaction record: " + err.Error()})
-			return
+		if failed {
+			report.fail(t.SourceUUID)
+			continue
 		}
+		report.accept(t)
+		valid = append(valid, t)
 	}
+	return valid, report
+}
+
+// ImportTransactions imports a CSV uploaded as multipart field "file".
+// Optional form fields: mode (merge/overwrite), profile (mapping profile ID)
+// and encoding (utf-8/big5/utf-16le/utf-16be; detected when omitted).
+// It runs the same pipeline as files sent to the LINE bot.
+func ImportTransactions(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	if !limitUploadBody(c) {
+		return
+	}
+
+	mode, err := parseImportMode(c.PostForm("mode"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	enc, err := parseEncoding(c.PostForm("encoding"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	file, ok := openUploadedFile(c)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	outcome, err := importCSV(userID, file, ImportOptions{Mode: mode, Profile: c.PostForm("profile"), Encoding: enc})
+	switch {
+	case errors.Is(err, errUnknownProfile):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, errFileTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, errOverwriteFailedRows):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, errInvalidCSV):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse CSV: " + err.Error()})
+		return
+	case err != nil:
+		utils.LogError("ImportTransactions: importCSV failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import transactions: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, outcome)
+}
+
+// uploadEnvelopeBytes leaves room for the multipart envelope and form
+// fields around the file
+const uploadEnvelopeBytes = 1 << 20
+
+// limitUploadBody caps the request body at the import size limit before
+// anything reads it, then parses the multipart form so PostForm and FormFile
+// see the limited body. It writes the error response itself and reports
+// false on failure.
+func limitUploadBody(c *gin.Context) bool {
+	limit := importMaxBytes() + uploadEnvelopeBytes
+	if c.Request.ContentLength > limit {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": errFileTooLarge.Error()})
+		return false
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
 
-	c.JSON(http.StatusOK, gin.H{"message": "Transactions saved successfully"})
+	if _, err := c.MultipartForm(); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": errFileTooLarge.Error()})
+			return false
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Expected a multipart/form-data upload"})
+		return false
+	}
+	return true
+}
+
+// openUploadedFile opens the multipart "file" field of a form parsed by
+// limitUploadBody. It writes the error response itself and reports false on failure.
+func openUploadedFile(c *gin.Context) (multipart.File, bool) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "multipart field \"file\" is required"})
+		return nil, false
+	}
+	if header.Size > importMaxBytes() {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": errFileTooLarge.Error()})
+		return nil, false
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to open uploaded file"})
+		return nil, false
+	}
+	return file, true
 }
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"ledger-lens/backend/database"
+	"ledger-lens/backend/models"
+	"ledger-lens/backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// entryTimestampLayout is how LastUpdated is written for rows entered by hand
+const entryTimestampLayout = "2006-01-02 15:04:05"
+
+// TransactionPatch is a partial update; omitted fields keep their value.
+// Amount is read in the row's currency after any currency change.
+type TransactionPatch struct {
+	Date         *string         `json:"date"`
+	Category     *string         `json:"category"`
+	MainCategory *string         `json:"mainCategory"`
+	Amount       json.RawMessage `json:"amount"`
+	Currency     *string         `json:"currency"`
+	Member       *string         `json:"member"`
+	Account      *string         `json:"account"`
+	Tags         *string         `json:"tags"`
+	Note         *string         `json:"note"`
+	Type         *string         `json:"type"`
+}
+
+// apply copies the present fields onto t
+func (p *TransactionPatch) apply(t *models.Transaction) error {
+	set := func(dst *string, src *string) {
+		if src != nil {
+			*dst = strings.TrimSpace(*src)
+		}
+	}
+	if p.Date != nil {
+		t.Date, t.DateRaw = strings.TrimSpace(*p.Date), "" // re-normalised by validateEntry
+	}
+	set(&t.Category, p.Category)
+	set(&t.MainCategory, p.MainCategory)
+	set(&t.Member, p.Member)
+	set(&t.Account, p.Account)
+	set(&t.Tags, p.Tags)
+	set(&t.Note, p.Note)
+	set(&t.Type, p.Type)
+
+	if p.Currency != nil {
+		currency := models.NormalizeCurrency(*p.Currency)
+		if len(p.Amount) == 0 {
+			// Keep the same decimal value, e.g. 12.50 stays 12.50 when switching USD to EUR
+			m, err := models.ParseMoney(t.Amount.Format(t.Currency), currency, models.DecimalPoint)
+			if err != nil {
+				return fmt.Errorf("amount %s cannot be expressed in %s: %w", t.Amount.Format(t.Currency), currency, err)
+			}
+			t.Amount = m
+		}
+		t.Currency = currency
+	}
+	if len(p.Amount) > 0 {
+		raw := strings.Trim(string(p.Amount), `"`)
+		m, err := models.ParseMoney(raw, t.Currency, models.DecimalPoint)
+		if err != nil {
+			return fmt.Errorf("amount: %w", err)
+		}
+		t.Amount = m
+	}
+	return nil
+}
+
+// fields lists the checked fields the patch touches, by JSON name. A
+// currency change counts as touching the amount it re-expresses.
+func (p *TransactionPatch) fields() map[string]bool {
+	return map[string]bool{
+		"date":   p.Date != nil,
+		"amount": len(p.Amount) > 0 || p.Currency != nil,
+		"type":   p.Type != nil,
+	}
+}
+
+// validateEntry applies the import checks plus the stricter rules for rows
+// entered one at a time: a known type and an unsigned amount. With fields
+// set, only errors in those fields count, so an imported row that breaks the
+// stricter rules can still have its other fields edited.
+func validateEntry(t *models.Transaction, fields map[string]bool) []ImportDiagnostic {
+	diags := validateTransaction(t)
+	for i := range diags {
+		if diags[i].Code == DiagUnknownType {
+			diags[i].Severity = SeverityError
+		}
+	}
+	if t.Type == "" {
+		diags = append(diags, ImportDiagnostic{Field: "type", Code: DiagMissingValue, Reason: "type is empty; use 支, 收 or 轉", Severity: SeverityError})
+	}
+	if t.Amount < 0 {
+		diags = append(diags, ImportDiagnostic{Field: "amount", Code: DiagInvalidAmount, Reason: "amount must not be negative; the type gives the direction", Severity: SeverityError})
+	}
+
+	var errs []ImportDiagnostic
+	for _, d := range diags {
+		if d.Severity == SeverityError && (fields == nil || fields[d.Field]) {
+			errs = append(errs, d)
+		}
+	}
+	return errs
+}
+
+// prepareEntry validates a hand-entered row and stamps it for saving. now is
+// the user's wall clock, matching the local times in exported CSVs. fields
+// limits validation as in validateEntry; nil checks the whole row.
+func prepareEntry(t *models.Transaction, now time.Time, fields map[string]bool) []ImportDiagnostic {
+	if errs := validateEntry(t, fields); len(errs) > 0 {
+		return errs
+	}
+	t.LastUpdated = now.Format(entryTimestampLayout)
+	t.Fingerprint = t.ComputeFingerprint()
+	return nil
+}
+
+// CreateTransaction adds a single transaction to the ledger
+func CreateTransaction(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var t models.Transaction
+	if err := c.ShouldBindJSON(&t); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, err := loadUserSettings(userID)
+	if err != nil {
+		utils.LogError("CreateTransaction: loadUserSettings failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load settings: " + err.Error()})
+		return
+	}
+
+	t.ID = uuid.Nil
+	t.UserID = userID
+	t.DateRaw = "" // always re-derived from Date
+	t.DuplicateOfID = nil
+	t.Origin = models.OriginWeb
+	if errs := prepareEntry(&t, settings.Now(), nil); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errs[0].Reason, "diagnostics": errs})
+		return
+	}
+
+	if err := database.DB.Create(&t).Error; err != nil {
+		utils.LogError("CreateTransaction: DB Create failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transaction: " + err.Error()})
+		return
+	}
+
+	notifyBudgetAlerts(userID)
+	c.JSON(http.StatusCreated, gin.H{"transaction": t})
+}
+
+// GetTransaction returns one of the user's transactions
+func GetTransaction(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	t, ok := findTransaction(c, userID)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"transaction": t})
+}
+
+// UpdateTransaction changes the fields present in the request body
+func UpdateTransaction(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	t, ok := findTransaction(c, userID)
+	if !ok {
+		return
+	}
+
+	var patch TransactionPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := patch.apply(&t); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	settings, err := loadUserSettings(userID)
+	if err != nil {
+		utils.LogError("UpdateTransaction: loadUserSettings failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load settings: " + err.Error()})
+		return
+	}
+	if errs := prepareEntry(&t, settings.Now(), patch.fields()); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errs[0].Reason, "diagnostics": errs})
+		return
+	}
+
+	if err := database.DB.Save(&t).Error; err != nil {
+		utils.LogError("UpdateTransaction: DB Save failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update transaction: " + err.Error()})
+		return
+	}
+
+	notifyBudgetAlerts(userID)
+	c.JSON(http.StatusOK, gin.H{"transaction": t})
+}
+
+// DeleteTransaction removes one transaction. Rows held as its probable
+// duplicates are released into the ledger, since there is nothing left to
+// compare them with.
+func DeleteTransaction(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	t, ok := findTransaction(c, userID)
+	if !ok {
+		return
+	}
+
+	if err := deleteTransaction(userID, t); err != nil {
+		utils.LogError("DeleteTransaction: DB Delete failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete transaction: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Transaction deleted"})
+}
+
+// deleteTransaction removes t and releases the rows held as its duplicates
+func deleteTransaction(userID uuid.UUID, t models.Transaction) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Transaction{}).
+			Where("user_id = ? AND duplicate_of_id = ?", userID, t.ID).
+			Update("duplicate_of_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&t).Error
+	})
+}
+
+// findTransaction loads the ledger row named by the :id parameter. Probable
+// duplicates held for review are not found; they are handled by the
+// duplicate endpoints.
+func findTransaction(c *gin.Context, userID uuid.UUID) (models.Transaction, bool) {
+	var t models.Transaction
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction id"})
+		return t, false
+	}
+	if err := database.DB.Scopes(ledgerScope(userID)).Where("id = ?", id).First(&t).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		} else {
+			utils.LogError("findTransaction: DB First failed", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load transaction: " + err.Error()})
+		}
+		return t, false
+	}
+	return t, true
+}
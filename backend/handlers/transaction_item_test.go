@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"ledger-lens/backend/models"
+)
+
+func TestTransactionPatch(t *testing.T) {
+	row := models.Transaction{Date: "20240105", DateRaw: "2024/01/05", Category: "午餐", Amount: 1250, Currency: "USD", Type: "支"}
+
+	var patch TransactionPatch
+	if err := json.Unmarshal([]byte(`{"currency": "EUR", "note": " 出差 ", "date": "2024/1/6"}`), &patch); err != nil {
+		t.Fatal(err)
+	}
+	if err := patch.apply(&row); err != nil {
+		t.Fatal(err)
+	}
+	if errs := validateEntry(&row, patch.fields()); len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %+v", errs)
+	}
+	if row.Amount != 1250 || row.Currency != "EUR" || row.Note != "出差" || row.Date != "20240106" || row.Category != "午餐" {
+		t.Errorf("Unexpected row after patch: %+v", row)
+	}
+
+	// 12.50 cannot become whole dollars without losing the cents
+	patch = TransactionPatch{}
+	if err := json.Unmarshal([]byte(`{"currency": "TWD"}`), &patch); err != nil {
+		t.Fatal(err)
+	}
+	if err := patch.apply(&row); err == nil {
+		t.Error("Expected error when switching 12.50 to TWD")
+	}
+}
+
+func TestValidateEntry(t *testing.T) {
+	row := models.Transaction{Date: "2024-02-30", Amount: -5, Type: "借"}
+	codes := map[string]bool{}
+	for _, d := range validateEntry(&row, nil) {
+		codes[d.Field+"/"+d.Code] = true
+	}
+	for _, want := range []string{"date/invalid_date", "amount/invalid_amount", "type/unknown_type"} {
+		if !codes[want] {
+			t.Errorf("Expected %s, got %v", want, codes)
+		}
+	}
+}
+
+func TestPatchImportedRow(t *testing.T) {
+	// Imported as-is: no 收支區分 and a bracketed negative amount
+	row := models.Transaction{Date: "20240105", DateRaw: "2024/01/05", Category: "退款", Amount: -12345, Currency: "TWD"}
+
+	var patch TransactionPatch
+	if err := json.Unmarshal([]byte(`{"note": "已入帳"}`), &patch); err != nil {
+		t.Fatal(err)
+	}
+	if err := patch.apply(&row); err != nil {
+		t.Fatal(err)
+	}
+	if errs := validateEntry(&row, patch.fields()); len(errs) > 0 {
+		t.Errorf("Expected the note to be editable, got %+v", errs)
+	}
+
+	patch = TransactionPatch{}
+	if err := json.Unmarshal([]byte(`{"amount": -5}`), &patch); err != nil {
+		t.Fatal(err)
+	}
+	if err := patch.apply(&row); err != nil {
+		t.Fatal(err)
+	}
+	errs := validateEntry(&row, patch.fields())
+	if len(errs) != 1 || errs[0].Field != "amount" {
+		t.Errorf("Expected only the patched amount rejected, got %+v", errs)
+	}
+}
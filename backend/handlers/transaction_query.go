@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ledger-lens/backend/models"
+	"ledger-lens/backend/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Page size limits for GET /transactions
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+var errInvalidQuery = errors.New("invalid query")
+
+// TransactionFilter narrows the ledger. Empty fields do not filter.
+type TransactionFilter struct {
+	Query          string   `json:"query,omitempty"` // search language, see ParseQuery
+	Types          []string `json:"types,omitempty"`
+	Categories     []string `json:"categories,omitempty"`
+	MainCategories []string `json:"mainCategories,omitempty"`
+	Accounts       []string `json:"accounts,omitempty"`
+	Members        []string `json:"members,omitempty"`
+	Tags           []string `json:"tags,omitempty"` // every tag must appear
+	From           string   `json:"from,omitempty"` // YYYYMMDD, inclusive
+	To             string   `json:"to,omitempty"`   // YYYYMMDD, inclusive
+	// Amount bounds are decimals in each row's own currency, e.g. "500" or "12.5"
+	MinAmount string `json:"minAmount,omitempty"`
+	MaxAmount string `json:"maxAmount,omitempty"`
+}
+
+// scope applies the filter to a transactions query. Errors in Query are *QueryError.
+func (f TransactionFilter) scope() (func(*gorm.DB) *gorm.DB, error) {
+	node, err := ParseQuery(f.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	var minMilli, maxMilli *int64
+	for _, b := range []struct {
+		value string
+		dst   **int64
+	}{{f.MinAmount, &minMilli}, {f.MaxAmount, &maxMilli}} {
+		if b.value == "" {
+			continue
+		}
+		v, err := models.ParseScaled(b.value, models.MaxCurrencyExponent)
+		if err != nil {
+			return nil, fmt.Errorf("%w: amount bound %q: %v", errInvalidQuery, b.value, err)
+		}
+		*b.dst = &v
+	}
+
+	return func(db *gorm.DB) *gorm.DB {
+		if node != nil {
+			sql, args := compileQuery(node)
+			db = db.Where("("+sql+")", args...)
+		}
+		for _, in := range []struct {
+			column string
+			values []string
+		}{
+			{"type", f.Types},
+			{"category", f.Categories},
+			{"main_category", f.MainCategories},
+			{"account", f.Accounts},
+			{"member", f.Members},
+		} {
+			if len(in.values) > 0 {
+				db = db.Where(in.column+" IN ?", in.values)
+			}
+		}
+		for _, tag := range f.Tags {
+			sql, args := tagCondition(tag)
+			db = db.Where(sql, args...)
+		}
+		if f.From != "" {
+			db = db.Where("date >= ?", f.From)
+		}
+		if f.To != "" {
+			db = db.Where("date <= ?", f.To)
+		}
+		if minMilli != nil {
+			db = db.Where(amountMilliSQL+" >= ?", *minMilli)
+		}
+		if maxMilli != nil {
+			db = db.Where(amountMilliSQL+" <= ?", *maxMilli)
+		}
+		return db
+	}, nil
+}
+
+// amountMilliSQL expresses amount_minor in units of 10^-MaxCurrencyExponent
+// so one bound can be compared against rows of every currency
+var amountMilliSQL = func() string {
+	currencies := make([]string, 0, len(models.CurrencyExponents))
+	for cur := range models.CurrencyExponents {
+		currencies = append(currencies, cur)
+	}
+	sort.Strings(currencies)
+
+	var b strings.Builder
+	b.WriteString("amount_minor * CASE currency")
+	for _, cur := range currencies {
+		fmt.Fprintf(&b, " WHEN '%s' THEN %d", cur, pow10(models.MaxCurrencyExponent-models.CurrencyExponents[cur]))
+	}
+	fmt.Fprintf(&b, " ELSE %d END", pow10(models.MaxCurrencyExponent-models.DefaultCurrencyExponent))
+	return b.String()
+}()
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for ; n > 0; n-- {
+		p *= 10
+	}
+	return p
+}
+
+// tagSeparators splits the stored tags text into whole tags, e.g. 公司,出差
+// or 公司、出差
+const tagSeparators = `[,，、;；\s]+`
+
+// tagCondition matches rows carrying the whole tag, ignoring case, so
+// tag:公司 does not match 公司聚餐
+func tagCondition(tag string) (string, []interface{}) {
+	return "lower(?) = ANY(regexp_split_to_array(lower(tags), '" + tagSeparators + "'))", []interface{}{strings.TrimSpace(tag)}
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// splitList reads a comma separated query value
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// parseQueryDate accepts any date NormalizeDate understands
+func parseQueryDate(name, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	d, err := utils.NormalizeDate(value)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %v", errInvalidQuery, name, err)
+	}
+	return d.Format(utils.StoredDateLayout), nil
+}
+
+// parseTransactionFilter reads q (a search query), type, category, mainCategory, account,
+// member, tag, from, to, minAmount and maxAmount. List parameters take comma
+// separated values.
+func parseTransactionFilter(query url.Values) (TransactionFilter, error) {
+	f := TransactionFilter{
+		Query:          strings.TrimSpace(query.Get("q")),
+		Types:          splitList(query.Get("type")),
+		Categories:     splitList(query.Get("category")),
+		MainCategories: splitList(query.Get("mainCategory")),
+		Accounts:       splitList(query.Get("account")),
+		Members:        splitList(query.Get("member")),
+		Tags:           splitList(query.Get("tag")),
+		MinAmount:      query.Get("minAmount"),
+		MaxAmount:      query.Get("maxAmount"),
+	}
+	var err error
+	if f.From, err = parseQueryDate("from", query.Get("from")); err != nil {
+		return f, err
+	}
+	if f.To, err = parseQueryDate("to", query.Get("to")); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+// sortField describes a column GET /transactions can be sorted by
+type sortField struct {
+	column string
+	value  func(t models.Transaction) interface{} // cursor value of a row
+	zero   func() interface{}                     // decoding target for a cursor value
+}
+
+func stringValue() interface{} { return new(string) }
+
+// transactionSortFields maps sort keys to columns. Amounts sort by minor
+// units, so mixed-currency ledgers are not ordered by value.
+var transactionSortFields = map[string]sortField{
+	"date":         {"date", func(t models.Transaction) interface{} { return t.Date }, stringValue},
+	"amount":       {"amount_minor", func(t models.Transaction) interface{} { return int64(t.Amount) }, func() interface{} { return new(int64) }},
+	"category":     {"category", func(t models.Transaction) interface{} { return t.Category }, stringValue},
+	"mainCategory": {"main_category", func(t models.Transaction) interface{} { return t.MainCategory }, stringValue},
+	"account":      {"account", func(t models.Transaction) interface{} { return t.Account }, stringValue},
+	"member":       {"member", func(t models.Transaction) interface{} { return t.Member }, stringValue},
+	"type":         {"type", func(t models.Transaction) interface{} { return t.Type }, stringValue},
+	"createdAt":    {"created_at", func(t models.Transaction) interface{} { return t.CreatedAt }, func() interface{} { return new(time.Time) }},
+}
+
+type sortKey struct {
+	field sortField
+	desc  bool
+}
+
+// parseSort reads "-date,amount": a leading minus sorts descending. Rows are
+// finally ordered by created_at and id so the order, and cursors, are stable.
+func parseSort(s string) ([]sortKey, error) {
+	if s == "" {
+		s = "date"
+	}
+	var keys []sortKey
+	seen := map[string]bool{}
+	for _, name := range splitList(s) {
+		desc := strings.HasPrefix(name, "-")
+		name = strings.TrimPrefix(name, "-")
+		field, ok := transactionSortFields[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: cannot sort by %q", errInvalidQuery, name)
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		keys = append(keys, sortKey{field, desc})
+	}
+	if !seen["createdAt"] {
+		keys = append(keys, sortKey{field: transactionSortFields["createdAt"]})
+	}
+	keys = append(keys, sortKey{field: sortField{
+		column: "id",
+		value:  func(t models.Transaction) interface{} { return t.ID },
+		zero:   func() interface{} { return new(uuid.UUID) },
+	}})
+	return keys, nil
+}
+
+func orderClause(keys []sortKey) string {
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k.field.column
+		if k.desc {
+			parts[i] += " DESC"
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// encodeCursor captures the sort values of the last row on a page
+func encodeCursor(keys []sortKey, last models.Transaction) string {
+	values := make([]interface{}, len(keys))
+	for i, k := range keys {
+		values[i] = k.field.value(last)
+	}
+	data, _ := json.Marshal(values)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// afterCursor restricts a query to rows following the cursor in keys order
+func afterCursor(keys []sortKey, cursor string) (func(*gorm.DB) *gorm.DB, error) {
+	invalid := fmt.Errorf("%w: malformed cursor", errInvalidQuery)
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, invalid
+	}
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil || len(raw) != len(keys) {
+		return nil, invalid
+	}
+	values := make([]interface{}, len(keys))
+	for i, k := range keys {
+		v := k.field.zero()
+		if err := json.Unmarshal(raw[i], v); err != nil {
+			return nil, invalid
+		}
+		values[i] = v
+	}
+
+	// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ..., with < for descending keys
+	var clauses []string
+	var args []interface{}
+	for i, k := range keys {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, keys[j].field.column+" = ?")
+			args = append(args, values[j])
+		}
+		op := " > ?"
+		if k.desc {
+			op = " < ?"
+		}
+		parts = append(parts, k.field.column+op)
+		args = append(args, values[i])
+		clauses = append(clauses, "("+strings.Join(parts, " AND ")+")")
+	}
+	where := "(" + strings.Join(clauses, " OR ") + ")"
+	return func(db *gorm.DB) *gorm.DB { return db.Where(where, args...) }, nil
+}
+
+// parsePageSize reads limit, defaulting to defaultPageSize. Every list is
+// paged; clients follow next_cursor for the rest.
+func parsePageSize(limit string) (int, error) {
+	if limit == "" {
+		return defaultPageSize, nil
+	}
+	n, err := strconv.Atoi(limit)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%w: limit must be a positive integer", errInvalidQuery)
+	}
+	if n > maxPageSize {
+		n = maxPageSize
+	}
+	return n, nil
+}
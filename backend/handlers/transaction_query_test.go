@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"ledger-lens/backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestParseTransactionFilter(t *testing.T) {
+	query, _ := url.ParseQuery("q=午餐&type=支,收&category=飲食&from=2024/1/1&to=113/03/31&minAmount=500")
+	f, err := parseTransactionFilter(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.From != "20240101" || f.To != "20240331" || len(f.Types) != 2 || f.Categories[0] != "飲食" || f.Query != "午餐" {
+		t.Errorf("Unexpected filter: %+v", f)
+	}
+	if _, err := f.scope(); err != nil {
+		t.Errorf("Unexpected scope error: %v", err)
+	}
+
+	f.MaxAmount = "12.3456"
+	if _, err := f.scope(); err == nil {
+		t.Error("Expected error for an amount bound with four decimals")
+	}
+	if _, err := parseTransactionFilter(url.Values{"from": {"01/02/2024"}}); err == nil {
+		t.Error("Expected error for ambiguous date")
+	}
+}
+
+func TestParseSortAndCursor(t *testing.T) {
+	keys, err := parseSort("-date,amount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := orderClause(keys); got != "date DESC, amount_minor, created_at, id" {
+		t.Errorf("Unexpected order clause: %s", got)
+	}
+	if _, err := parseSort("password"); err == nil {
+		t.Error("Expected error for unknown sort key")
+	}
+
+	last := models.Transaction{ID: uuid.New(), Date: "20240105", Amount: 120, CreatedAt: time.Now()}
+	cursor := encodeCursor(keys, last)
+	if _, err := afterCursor(keys, cursor); err != nil {
+		t.Errorf("Cursor did not round trip: %v", err)
+	}
+	if _, err := afterCursor(keys[:2], cursor); err == nil {
+		t.Error("Expected error for a cursor from a different sort")
+	}
+	if _, err := afterCursor(keys, "not-a-cursor"); err == nil {
+		t.Error("Expected error for malformed cursor")
+	}
+}
+
+func TestTagFilterMatchesWholeTags(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=unused"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scope, err := TransactionFilter{Tags: []string{"公司"}}.scope()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmt := db.Model(&models.Transaction{}).Scopes(scope).Find(&[]models.Transaction{}).Statement
+
+	if sql := stmt.SQL.String(); !strings.Contains(sql, "= ANY(regexp_split_to_array(lower(tags)") || strings.Contains(sql, "ILIKE") {
+		t.Errorf("Expected a whole-tag match, got %s", sql)
+	}
+	if !reflect.DeepEqual(stmt.Vars, []interface{}{"公司"}) {
+		t.Errorf("Expected the bare tag as the only argument, got %v", stmt.Vars)
+	}
+}
+
+func TestParsePageSize(t *testing.T) {
+	cases := map[string]int{"": defaultPageSize, "20": 20, "5000": maxPageSize}
+	for limit, want := range cases {
+		if got, err := parsePageSize(limit); err != nil || got != want {
+			t.Errorf("parsePageSize(%q) = %d, %v; want %d", limit, got, err, want)
+		}
+	}
+	for _, limit := range []string{"0", "-1", "all"} {
+		if _, err := parsePageSize(limit); err == nil {
+			t.Errorf("parsePageSize(%q) accepted", limit)
+		}
+	}
+}
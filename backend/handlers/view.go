@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"ledger-lens/backend/database"
+	"ledger-lens/backend/models"
+	"ledger-lens/backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+type ViewInput struct {
+	Name   string            `json:"name" binding:"required"`
+	Filter models.ViewFilter `json:"filter"`
+	Sort   string            `json:"sort"`
+}
+
+// validate normalises dates and checks the view can be evaluated
+func (in *ViewInput) validate() error {
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		return errors.New("name must not be empty")
+	}
+	if in.Filter.DateWindow != "" && (in.Filter.From != "" || in.Filter.To != "") {
+		return errors.New("set either dateWindow or from/to, not both")
+	}
+
+	var err error
+	if in.Filter.From, err = parseQueryDate("from", in.Filter.From); err != nil {
+		return err
+	}
+	if in.Filter.To, err = parseQueryDate("to", in.Filter.To); err != nil {
+		return err
+	}
+	if _, err := parseSort(in.Sort); err != nil {
+		return err
+	}
+	filter, err := viewTransactionFilter(in.Filter, time.Now(), models.DefaultMonthStartDay)
+	if err != nil {
+		return err
+	}
+	_, err = filter.scope()
+	return err
+}
+
+func (in *ViewInput) apply(v *models.SavedView) {
+	v.Name = in.Name
+	v.Filter = datatypes.NewJSONType(in.Filter)
+	v.Sort = in.Sort
+}
+
+// viewTransactionFilter resolves a stored filter as of today, with months
+// beginning on monthStartDay
+func viewTransactionFilter(v models.ViewFilter, today time.Time, monthStartDay int) (TransactionFilter, error) {
+	f := TransactionFilter{
+		Query:          v.Query,
+		Types:          v.Types,
+		Categories:     v.Categories,
+		MainCategories: v.MainCategories,
+		Accounts:       v.Accounts,
+		Members:        v.Members,
+		Tags:           v.Tags,
+		From:           v.From,
+		To:             v.To,
+		MinAmount:      v.MinAmount,
+		MaxAmount:      v.MaxAmount,
+	}
+	if v.DateWindow != "" {
+		var err error
+		if f.From, f.To, err = resolveDateWindow(v.DateWindow, today, monthStartDay); err != nil {
+			return f, err
+		}
+	}
+	return f, nil
+}
+
+// GetViews lists the user's saved views
+func GetViews(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	views := []models.SavedView{}
+	if err := database.DB.Where("user_id = ?", userID).Order("name").Find(&views).Error; err != nil {
+		utils.LogError("GetViews: DB Find failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load views: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"views": views})
+}
+
+// CreateView saves a named filter
+func CreateView(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var input ViewInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := input.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, queryErrorResponse(err))
+		return
+	}
+
+	view := models.SavedView{UserID: userID}
+	input.apply(&view)
+	err := database.DB.Create(&view).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("A view named %q already exists", view.Name)})
+		return
+	}
+	if err != nil {
+		utils.LogError("CreateView: DB Create failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create view: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"view": view})
+}
+
+// GetView returns one saved view's definition
+func GetView(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	view, ok := findView(c, userID)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"view": view})
+}
+
+// UpdateView replaces a saved view
+func UpdateView(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	view, ok := findView(c, userID)
+	if !ok {
+		return
+	}
+
+	var input ViewInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := input.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, queryErrorResponse(err))
+		return
+	}
+
+	input.apply(&view)
+	err := database.DB.Save(&view).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("A view named %q already exists", view.Name)})
+		return
+	}
+	if err != nil {
+		utils.LogError("UpdateView: DB Save failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update view: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"view": view})
+}
+
+// DeleteView removes a saved view
+func DeleteView(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	view, ok := findView(c, userID)
+	if !ok {
+		return
+	}
+
+	if err := database.DB.Delete(&view).Error; err != nil {
+		utils.LogError("DeleteView: DB Delete failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete view: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "View deleted"})
+}
+
+// GetViewTransactions evaluates a saved view, resolving relative dates as of
+// today in the user's time zone. It pages like GET /transactions; a sort parameter overrides the view's.
+func GetViewTransactions(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	view, ok := findView(c, userID)
+	if !ok {
+		return
+	}
+
+	settings, err := loadUserSettings(userID)
+	if err != nil {
+		utils.LogError("GetViewTransactions: loadUserSettings failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load settings: " + err.Error()})
+		return
+	}
+	filter, err := viewTransactionFilter(view.Filter.Data(), settings.Now(), settings.MonthStartDay)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	sort := view.Sort
+	if s := c.Query("sort"); s != "" {
+		sort = s
+	}
+
+	listTransactions(c, userID, filter, sort, gin.H{"view": view, "from": filter.From, "to": filter.To})
+}
+
+func findView(c *gin.Context, userID uuid.UUID) (models.SavedView, bool) {
+	var view models.SavedView
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid view id"})
+		return view, false
+	}
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&view).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "View not found"})
+		return view, false
+	}
+	return view, true
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestViewDuplicateName(t *testing.T) {
+	useFailingWritesDB(t)
+	body := `{"name": "本月餐飲", "filter": {"categories": ["午餐"]}}`
+
+	if w := callHandler(CreateView, http.MethodPost, body); w.Code != http.StatusConflict {
+		t.Errorf("create: status %d, want 409: %s", w.Code, w.Body)
+	}
+	id := gin.Param{Key: "id", Value: uuid.NewString()}
+	if w := callHandler(UpdateView, http.MethodPut, body, id); w.Code != http.StatusConflict {
+		t.Errorf("update: status %d, want 409: %s", w.Code, w.Body)
+	}
+}
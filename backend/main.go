@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 	"os"
+	_ "time/tzdata" // user time zones must load on hosts without zoneinfo
 
 	"ledger-lens/backend/database"
 	"ledger-lens/backend/routes"
@@ -19,6 +20,8 @@ func main() {
 
 	// Connect to database
 	database.Connect()
+	database.Migrate()
+	database.LoadExchangeRatesFromEnv()
 
 	// Initialize Gin
 	r := gin.Default()
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// What a budget's Category names
+const (
+	BudgetScopeCategory     = "category"
+	BudgetScopeMainCategory = "mainCategory"
+)
+
+// Budget periods. Months follow the user's fiscal month start day.
+const (
+	BudgetPeriodWeek  = "week"
+	BudgetPeriodMonth = "month"
+	BudgetPeriodYear  = "year"
+)
+
+// What carries over from one period to the next
+const (
+	RolloverNone    = "none"    // every period starts from the limit
+	RolloverUnspent = "unspent" // leftovers are added, overspending is forgiven
+	RolloverAll     = "all"     // leftovers are added and overspending is deducted
+)
+
+// Budget limits spending in one category per period
+type Budget struct {
+	ID       uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_budgets_user_target,priority:1" json:"-"`
+	Scope    string    `gorm:"type:varchar(16);not null;default:'category';uniqueIndex:idx_budgets_user_target,priority:2" json:"scope"`
+	Category string    `gorm:"not null;uniqueIndex:idx_budgets_user_target,priority:3" json:"category"`
+	Period   string    `gorm:"type:varchar(8);not null;default:'month';uniqueIndex:idx_budgets_user_target,priority:4" json:"period"`
+	Amount   Money     `gorm:"column:amount_minor;not null" json:"-"`
+	Currency string    `gorm:"type:varchar(8);not null;default:'TWD'" json:"currency"`
+	Rollover string    `gorm:"type:varchar(8);not null;default:'none'" json:"rollover"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Relationship
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// MarshalJSON emits amount as a decimal number in the budget's currency
+func (b Budget) MarshalJSON() ([]byte, error) {
+	type plain Budget
+	return json.Marshal(struct {
+		plain
+		Amount json.RawMessage `json:"amount"`
+	}{plain(b), json.RawMessage(b.Amount.Format(b.Currency))})
+}
+
+// BudgetAlert records a threshold already announced for one budget period,
+// so each fires at most once per period
+type BudgetAlert struct {
+	ID         uint      `gorm:"primaryKey" json:"-"`
+	BudgetID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_budget_alerts_once,priority:1" json:"budget_id"`
+	PeriodFrom string    `gorm:"type:varchar(8);not null;uniqueIndex:idx_budget_alerts_once,priority:2" json:"period_from"` // YYYYMMDD
+	Threshold  int       `gorm:"not null;uniqueIndex:idx_budget_alerts_once,priority:3" json:"threshold"`                   // percent
+	SentAt     time.Time `gorm:"autoCreateTime" json:"sent_at"`
+
+	// Relationship
+	Budget Budget `gorm:"foreignKey:BudgetID;constraint:OnDelete:CASCADE" json:"-"`
+}
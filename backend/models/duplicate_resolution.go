@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// How the user resolved a probable duplicate
+const (
+	DuplicateConfirmed = "confirmed" // the held row was discarded
+	DuplicateDismissed = "dismissed" // the held row was kept as a genuine transaction
+)
+
+// DuplicateResolution remembers the user's review of a fingerprint, so later
+// imports of the same row are not held for review again
+type DuplicateResolution struct {
+	UserID      uuid.UUID `gorm:"type:uuid;primaryKey" json:"-"`
+	Fingerprint string    `gorm:"type:char(64);primaryKey" json:"-"`
+	Resolution  string    `gorm:"type:varchar(16);not null" json:"resolution"`
+	ResolvedAt  time.Time `gorm:"autoUpdateTime" json:"resolved_at"`
+
+	// Relationship
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// ExchangeRate quotes one unit of Base in Currency on a date: 1 Base = Rate Currency.
+// Rates are shared by all users and loaded from a CSV or ECB XML file.
+type ExchangeRate struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	Date      string    `gorm:"type:varchar(8);not null;uniqueIndex:idx_exchange_rates_pair_date,priority:3" json:"date"` // YYYYMMDD
+	Base      string    `gorm:"type:varchar(8);not null;uniqueIndex:idx_exchange_rates_pair_date,priority:1" json:"base"`
+	Currency  string    `gorm:"type:varchar(8);not null;uniqueIndex:idx_exchange_rates_pair_date,priority:2" json:"currency"`
+	Rate      string    `gorm:"type:numeric(24,10);not null" json:"rate"` // kept as decimal text so no precision is lost
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"-"`
+}
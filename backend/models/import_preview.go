@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// ImportPreview holds a computed import change set until the user commits it
+type ImportPreview struct {
+	ID            uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID        uuid.UUID      `gorm:"type:uuid;not null;index" json:"-"`
+	Plan          datatypes.JSON `gorm:"type:jsonb;not null" json:"-"`
+	LedgerVersion string         `gorm:"type:char(64);not null" json:"-"` // ledger state the plan was computed against
+	ExpiresAt     time.Time      `gorm:"index" json:"expires_at"`
+	CreatedAt     time.Time      `gorm:"autoCreateTime" json:"created_at"`
+
+	// Relationship
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
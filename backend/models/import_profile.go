@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// Sign conventions for signed amount columns
+const (
+	SignTypeColumn      = "type_column"         // amounts are unsigned; 收支區分 gives the type
+	SignNegativeExpense = "negative_is_expense" // bank style: withdrawals are negative
+	SignNegativeIncome  = "negative_is_income"  // card statement style: refunds are negative
+)
+
+// Decimal separators
+const (
+	DecimalPoint = "."
+	DecimalComma = ","
+)
+
+// ImportProfile is a user-defined CSV column mapping
+type ImportProfile struct {
+	ID     uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_import_profiles_user_name,priority:1" json:"-"`
+	Name   string    `gorm:"not null;uniqueIndex:idx_import_profiles_user_name,priority:2" json:"name"`
+	// Columns maps header text to a canonical field name such as "date" or "amount"
+	Columns          datatypes.JSONType[map[string]string] `gorm:"type:jsonb;not null" json:"columns"`
+	DateFormat       string                                `json:"date_format"` // e.g. YYYY/MM/DD; empty to auto-detect
+	DecimalSeparator string                                `gorm:"type:varchar(1);default:'.'" json:"decimal_separator"`
+	SignConvention   string                                `gorm:"default:'type_column'" json:"sign_convention"`
+	CreatedAt        time.Time                             `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time                             `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Relationship
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
@@ -0,0 +1,275 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"ledger-lens/backend/utils"
+)
+
+// Money is an exact amount in a currency's minor unit: whole dollars for
+// TWD, cents for USD. The currency is stored alongside, never inside.
+type Money int64
+
+// DefaultCurrency is assumed for rows without 貨幣
+const DefaultCurrency = "TWD"
+
+// DefaultCurrencyExponent applies to currencies missing from CurrencyExponents
+const DefaultCurrencyExponent = 2
+
+// MaxCurrencyExponent is the largest exponent in CurrencyExponents
+const MaxCurrencyExponent = 3
+
+// CurrencyExponents lists currencies whose minor unit is not 1/100.
+// TWD is kept at 0 because consumer ledgers never record cents.
+var CurrencyExponents = map[string]int{
+	"TWD": 0,
+	"JPY": 0,
+	"KRW": 0,
+	"VND": 0,
+	"CLP": 0,
+	"ISK": 0,
+	"BHD": 3,
+	"JOD": 3,
+	"KWD": 3,
+	"OMR": 3,
+	"TND": 3,
+}
+
+// currencyAliases maps names and symbols seen in exports to ISO codes
+var currencyAliases = map[string]string{
+	"NTD":  "TWD",
+	"NT$":  "TWD",
+	"新台幣":  "TWD",
+	"新臺幣":  "TWD",
+	"台幣":   "TWD",
+	"臺幣":   "TWD",
+	"RMB":  "CNY",
+	"人民幣":  "CNY",
+	"美元":   "USD",
+	"US$":  "USD",
+	"日圓":   "JPY",
+	"日元":   "JPY",
+	"歐元":   "EUR",
+	"EURO": "EUR",
+}
+
+// currencySymbols are stripped from amounts before parsing; longest first
+var currencySymbols = []string{"NT$", "US$", "HK$", "NTD", "TWD", "USD", "JPY", "EUR", "$", "¥", "€", "£", "₩", "元", "円"}
+
+var (
+	// ErrInvalidAmount is returned for text that is not a number
+	ErrInvalidAmount = errors.New("invalid amount")
+	// ErrPrecisionLoss is returned, with a rounded value, when an amount has
+	// more decimals than its currency allows
+	ErrPrecisionLoss = errors.New("amount has more decimals than the currency allows")
+)
+
+// NormalizeCurrency upper-cases a currency code, resolves common aliases and
+// falls back to DefaultCurrency when empty
+func NormalizeCurrency(currency string) string {
+	c := strings.ToUpper(strings.TrimSpace(utils.HalfWidth(currency)))
+	if c == "" {
+		return DefaultCurrency
+	}
+	if alias, ok := currencyAliases[c]; ok {
+		return alias
+	}
+	return c
+}
+
+// CurrencyExponent returns the number of decimals of the currency's minor unit
+func CurrencyExponent(currency string) int {
+	if exp, ok := CurrencyExponents[NormalizeCurrency(currency)]; ok {
+		return exp
+	}
+	return DefaultCurrencyExponent
+}
+
+// ParseMoney parses amounts as they appear in exports: thousands separators,
+// currency symbols, full-width digits, leading or trailing minus signs and
+// accounting-style parentheses for negatives. decimalSeparator is "." or ",".
+// Excess decimals are rounded half away from zero and reported with ErrPrecisionLoss.
+func ParseMoney(value, currency, decimalSeparator string) (Money, error) {
+	minor, err := parseScaled(value, CurrencyExponent(currency), decimalSeparator)
+	if errors.Is(err, ErrPrecisionLoss) {
+		err = fmt.Errorf("%w: %q in %s", ErrPrecisionLoss, value, NormalizeCurrency(currency))
+	}
+	return Money(minor), err
+}
+
+// ParseScaled reads a decimal such as a filter bound as an integer count of 10^-exp
+func ParseScaled(value string, exp int) (int64, error) {
+	return parseScaled(value, exp, DecimalPoint)
+}
+
+func parseScaled(value string, exp int, decimalSeparator string) (int64, error) {
+	s := strings.TrimSpace(utils.HalfWidth(value))
+	if s == "" {
+		return 0, fmt.Errorf("%w: empty", ErrInvalidAmount)
+	}
+
+	negative := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative = true
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "−") {
+		negative = !negative
+		s = strings.TrimSpace(strings.TrimLeft(s, "-−"))
+	} else if strings.HasSuffix(s, "-") {
+		negative = !negative
+		s = strings.TrimSpace(strings.TrimSuffix(s, "-"))
+	}
+	s = strings.TrimPrefix(s, "+")
+
+	upper := strings.ToUpper(s)
+	for _, sym := range currencySymbols {
+		if strings.HasPrefix(upper, sym) {
+			s, upper = s[len(sym):], upper[len(sym):]
+		}
+		if strings.HasSuffix(upper, sym) {
+			s, upper = s[:len(s)-len(sym)], upper[:len(upper)-len(sym)]
+		}
+	}
+	s = strings.TrimSpace(s)
+	// A sign may also follow the symbol, as in "NT$-120"
+	if strings.HasPrefix(s, "-") {
+		negative = !negative
+		s = s[1:]
+	}
+
+	thousands := ","
+	if decimalSeparator == DecimalComma {
+		thousands = "."
+	}
+	s = strings.NewReplacer(thousands, "", " ", "", "'", "").Replace(s)
+
+	intPart, fracPart := s, ""
+	if i := strings.Index(s, decimalSeparator); decimalSeparator != "" && i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+	if intPart == "" && fracPart == "" || !isDigits(intPart) || !isDigits(fracPart) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, value)
+	}
+	if intPart == "" {
+		intPart = "0"
+	}
+
+	var precisionErr error
+	roundUp := false
+	if len(fracPart) > exp {
+		if strings.Trim(fracPart[exp:], "0") != "" {
+			precisionErr = ErrPrecisionLoss
+			roundUp = fracPart[exp] >= '5'
+		}
+		fracPart = fracPart[:exp]
+	}
+	fracPart += strings.Repeat("0", exp-len(fracPart))
+
+	minor, err := strconv.ParseInt(intPart+fracPart, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, value)
+	}
+	if roundUp {
+		minor++
+	}
+	if negative {
+		minor = -minor
+	}
+	return minor, precisionErr
+}
+
+// MoneyFromFloat converts a float amount, rounding to the currency's minor unit
+func MoneyFromFloat(amount float64, currency string) Money {
+	m, _ := ParseMoney(strconv.FormatFloat(amount, 'f', -1, 64), currency, DecimalPoint)
+	return m
+}
+
+// Format renders the amount as a plain decimal with the currency's precision, e.g. "-12.50"
+func (m Money) Format(currency string) string {
+	exp := CurrencyExponent(currency)
+	minor := int64(m)
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	digits := strconv.FormatInt(minor, 10)
+	if exp == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+}
+
+// Convert applies rate, the price of one unit of from in to, and rounds half
+// away from zero to the minor unit of to
+func (m Money) Convert(from, to string, rate *big.Rat) Money {
+	v := new(big.Rat).SetInt64(int64(m))
+	v.Mul(v, rate)
+	shift := CurrencyExponent(to) - CurrencyExponent(from)
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(shift))), nil))
+	if shift >= 0 {
+		v.Mul(v, scale)
+	} else {
+		v.Quo(v, scale)
+	}
+
+	num := new(big.Int).Abs(v.Num())
+	q, r := new(big.Int).QuoRem(num, v.Denom(), new(big.Int))
+	if r.Mul(r, big.NewInt(2)).Cmp(v.Denom()) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if v.Sign() < 0 {
+		q.Neg(q)
+	}
+	return Money(q.Int64())
+}
+
+// Abs returns the absolute value
+func (m Money) Abs() Money {
+	if m < 0 {
+		return -m
+	}
+	return m
+}
+
+// Totals sums amounts per currency so precisions never mix
+type Totals map[string]Money
+
+// Add accumulates an amount in the given currency
+func (t Totals) Add(currency string, m Money) {
+	t[NormalizeCurrency(currency)] += m
+}
+
+// MarshalJSON renders each total as a JSON number with its currency's precision
+func (t Totals) MarshalJSON() ([]byte, error) {
+	out := make(map[string]json.RawMessage, len(t))
+	for currency, m := range t {
+		out[currency] = json.RawMessage(m.Format(currency))
+	}
+	return json.Marshal(out)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
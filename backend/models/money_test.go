@@ -0,0 +1,87 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	cases := []struct {
+		value    string
+		currency string
+		sep      string
+		want     Money
+	}{
+		{"1,234", "TWD", DecimalPoint, 1234},
+		{"NT$1,234", "", DecimalPoint, 1234},
+		{"(1,234.50)", "USD", DecimalPoint, -123450},
+		{"$ -0.05", "USD", DecimalPoint, -5},
+		{"１２，３４５元", "TWD", DecimalPoint, 12345},
+		{"120-", "JPY", DecimalPoint, -120},
+		{"1.234,5", "EUR", DecimalComma, 123450},
+		{"1.250", "KWD", DecimalPoint, 1250},
+		{"300.00", "TWD", DecimalPoint, 300},
+	}
+	for _, tc := range cases {
+		got, err := ParseMoney(tc.value, tc.currency, tc.sep)
+		if err != nil || got != tc.want {
+			t.Errorf("ParseMoney(%q, %q) = %v, %v; want %v", tc.value, tc.currency, got, err, tc.want)
+		}
+	}
+
+	if got, err := ParseMoney("10.5", "TWD", DecimalPoint); !errors.Is(err, ErrPrecisionLoss) || got != 11 {
+		t.Errorf("Expected 11 with ErrPrecisionLoss, got %v, %v", got, err)
+	}
+	for _, bad := range []string{"", "abc", "12a", "1.2.3", "$"} {
+		if _, err := ParseMoney(bad, "USD", DecimalPoint); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("Expected ErrInvalidAmount for %q, got %v", bad, err)
+		}
+	}
+}
+
+func TestMoneyFormat(t *testing.T) {
+	if s := Money(-5).Format("USD"); s != "-0.05" {
+		t.Errorf("Expected -0.05, got %s", s)
+	}
+	if s := Money(1234).Format("TWD"); s != "1234" {
+		t.Errorf("Expected 1234, got %s", s)
+	}
+	if s := Money(1250).Format("KWD"); s != "1.250" {
+		t.Errorf("Expected 1.250, got %s", s)
+	}
+}
+
+func TestTransactionAmountJSON(t *testing.T) {
+	in := Transaction{Date: "20240101", Amount: 1999, Currency: "USD"}
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if string(fields["amount"]) != "19.99" {
+		t.Errorf("Expected amount 19.99, got %s", fields["amount"])
+	}
+
+	var out Transaction
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Amount != in.Amount || out.Currency != in.Currency || out.Date != in.Date {
+		t.Errorf("Round trip mismatch: %+v", out)
+	}
+
+	out = Transaction{}
+	if err := json.Unmarshal([]byte(`{"amount": 10.5, "currency": "TWD"}`), &out); err != nil {
+		t.Fatalf("Expected fractional TWD amount to be rounded, got %v", err)
+	}
+	if out.Amount != 11 || out.RoundedFrom != "10.5" {
+		t.Errorf("Expected 11 rounded from 10.5, got %d from %q", out.Amount, out.RoundedFrom)
+	}
+	if err := json.Unmarshal([]byte(`{"amount": "abc", "currency": "TWD"}`), &out); err == nil {
+		t.Error("Expected error for non-numeric amount")
+	}
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// ViewFilter is a stored filter definition. DateWindow, when set, is resolved
+// relative to the day the view is evaluated and takes precedence over From/To.
+type ViewFilter struct {
+	Query          string   `json:"query,omitempty"` // search language
+	Types          []string `json:"types,omitempty"`
+	Categories     []string `json:"categories,omitempty"`
+	MainCategories []string `json:"mainCategories,omitempty"`
+	Accounts       []string `json:"accounts,omitempty"`
+	Members        []string `json:"members,omitempty"`
+	Tags           []string `json:"tags,omitempty"`
+	DateWindow     string   `json:"dateWindow,omitempty"` // e.g. last_30_days, this_month
+	From           string   `json:"from,omitempty"`
+	To             string   `json:"to,omitempty"`
+	MinAmount      string   `json:"minAmount,omitempty"`
+	MaxAmount      string   `json:"maxAmount,omitempty"`
+}
+
+// SavedView is a named filter and sort order for the transaction list
+type SavedView struct {
+	ID        uuid.UUID                      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID    uuid.UUID                      `gorm:"type:uuid;not null;uniqueIndex:idx_saved_views_user_name,priority:1" json:"-"`
+	Name      string                         `gorm:"not null;uniqueIndex:idx_saved_views_user_name,priority:2" json:"name"`
+	Filter    datatypes.JSONType[ViewFilter] `gorm:"type:jsonb;not null" json:"filter"`
+	Sort      string                         `json:"sort"` // e.g. -date,amount
+	CreatedAt time.Time                      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time                      `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Relationship
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Where a transaction was entered by hand. Imported rows have no origin.
+const (
+	OriginWeb  = "web"
+	OriginLine = "line"
+)
+
+// Transaction is a single ledger entry owned by a user.
+// JSON keys follow the frontend Transaction interface.
+type Transaction struct {
+	ID           uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID       uuid.UUID `gorm:"type:uuid;not null;index:idx_transactions_user_date,priority:1;index:idx_transactions_user_category,priority:1;index:idx_transactions_user_source,priority:1;index:idx_transactions_user_fingerprint,priority:1" json:"-"`
+	Date         string    `gorm:"type:varchar(32);index:idx_transactions_user_date,priority:2" json:"date"` // 日期 (YYYYMMDD)
+	DateRaw      string    `gorm:"type:varchar(64)" json:"dateRaw"`                                          // 日期原始文字
+	Category     string    `gorm:"index:idx_transactions_user_category,priority:2" json:"category"`          // 類別
+	MainCategory string    `json:"mainCategory"`                                                             // 大類別
+	Amount       Money     `gorm:"column:amount_minor;not null;default:0" json:"-"`                          // 金額 (最小單位)
+	Currency     string    `gorm:"type:varchar(8)" json:"currency"`                                          // 貨幣
+	Member       string    `json:"member"`                                                                   // 成員
+	Account      string    `json:"account"`                                                                  // 帳戶
+	Tags         string    `json:"tags"`                                                                     // 標籤
+	Note         string    `gorm:"type:text" json:"note"`                                                    // 備註
+	Type         string    `gorm:"type:varchar(8)" json:"type"`                                              // 收支區分
+	LastUpdated  string    `json:"lastUpdated"`                                                              // 上次更新
+	SourceUUID   string    `gorm:"index:idx_transactions_user_source,priority:2" json:"uuid"`                // 原始 CSV 的 UUID
+	Origin       string    `gorm:"type:varchar(8)" json:"origin,omitempty"`                                  // 建立來源, empty for imports
+	Fingerprint  string    `gorm:"type:char(64);index:idx_transactions_user_fingerprint,priority:2" json:"-"`
+	// DuplicateOfID marks a probable duplicate awaiting user confirmation.
+	// Such rows are excluded from the ledger until dismissed.
+	DuplicateOfID *uuid.UUID `gorm:"type:uuid;index" json:"duplicateOf,omitempty"`
+	// BaseAmount is Amount converted into BaseCurrency for display; not stored
+	BaseAmount   *Money `gorm:"-" json:"-"`
+	BaseCurrency string `gorm:"-" json:"baseCurrency,omitempty"`
+	// RoundedFrom is the posted amount when it had more decimals than the
+	// currency allows and was rounded on decode; not stored
+	RoundedFrom string    `gorm:"-" json:"-"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"-"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"-"`
+
+	// Relationship
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// ComputeFingerprint hashes the fields that identify a transaction when the
+// export carries no UUID: date, amount, account, category and note.
+func (t Transaction) ComputeFingerprint() string {
+	parts := []string{
+		strings.TrimSpace(t.Date),
+		t.Amount.Format(t.Currency),
+		strings.TrimSpace(t.Account),
+		strings.TrimSpace(t.Category),
+		strings.Join(strings.Fields(t.Note), " "),
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x1f")))
+	return hex.EncodeToString(sum[:])
+}
+
+// MarshalJSON writes amount as a decimal number in the row's currency,
+// and baseAmount in BaseCurrency when it has been converted
+func (t Transaction) MarshalJSON() ([]byte, error) {
+	type plain Transaction
+	out := struct {
+		plain
+		Amount     json.RawMessage `json:"amount"`
+		BaseAmount json.RawMessage `json:"baseAmount,omitempty"`
+	}{plain: plain(t), Amount: json.RawMessage(t.Amount.Format(t.Currency))}
+	if t.BaseAmount != nil {
+		out.BaseAmount = json.RawMessage(t.BaseAmount.Format(t.BaseCurrency))
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON reads amount as a number or numeric string. Amounts with more
+// decimals than the currency allows are rounded, as CSV imports are, and the
+// posted text is kept in RoundedFrom for the caller to report.
+func (t *Transaction) UnmarshalJSON(data []byte) error {
+	type plain Transaction
+	aux := struct {
+		*plain
+		Amount json.RawMessage `json:"amount"`
+	}{plain: (*plain)(t)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	t.Amount = 0
+	raw := strings.Trim(string(aux.Amount), `"`)
+	if raw == "" || raw == "null" {
+		return nil
+	}
+	m, err := ParseMoney(raw, t.Currency, DecimalPoint)
+	if errors.Is(err, ErrPrecisionLoss) {
+		t.Amount, t.RoundedFrom = m, raw
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("amount: %w", err)
+	}
+	t.Amount = m
+	return nil
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Fiscal month start days. Days past 28 would not exist in February.
+const (
+	DefaultMonthStartDay = 1
+	MaxMonthStartDay     = 28
+)
+
+// Defaults for users who have not chosen a zone or locale
+const (
+	DefaultTimeZone = "Asia/Taipei"
+	DefaultLocale   = "zh-TW"
+)
+
+// Which budget alerts are pushed through LINE
+const (
+	BudgetAlertsAll  = "all"  // the warning threshold and going over
+	BudgetAlertsOver = "over" // only going over
+	BudgetAlertsOff  = "off"
+
+	DefaultBudgetWarnPercent = 80
+)
+
+// UserSettings holds per-user preferences. Users without a row get the defaults.
+type UserSettings struct {
+	UserID       uuid.UUID `gorm:"type:uuid;primaryKey" json:"-"`
+	BaseCurrency string    `gorm:"type:varchar(8);not null;default:'TWD'" json:"base_currency"` // summaries are converted into it
+	// MonthStartDay is the day fiscal months begin, e.g. 25 for payday-based months
+	MonthStartDay int    `gorm:"not null;default:1" json:"month_start_day"`
+	TimeZone      string `gorm:"type:varchar(64);not null;default:'Asia/Taipei'" json:"time_zone"` // IANA name; decides "today"
+	Locale        string `gorm:"type:varchar(35);not null;default:'zh-TW'" json:"locale"`          // BCP 47 tag for clients to format with
+	BudgetAlerts  string `gorm:"type:varchar(8);not null;default:'all'" json:"budget_alerts"`
+	// BudgetWarnPercent is the share of a budget that triggers the early warning
+	BudgetWarnPercent int       `gorm:"not null;default:80" json:"budget_warn_percent"`
+	UpdatedAt         time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Relationship
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// DefaultUserSettings returns the settings used until the user saves their own
+func DefaultUserSettings(userID uuid.UUID) UserSettings {
+	return UserSettings{
+		UserID:        userID,
+		BaseCurrency:  DefaultCurrency,
+		MonthStartDay: DefaultMonthStartDay,
+		TimeZone:      DefaultTimeZone,
+		Locale:        DefaultLocale,
+
+		BudgetAlerts:      BudgetAlertsAll,
+		BudgetWarnPercent: DefaultBudgetWarnPercent,
+	}
+}
+
+// Location returns the user's time zone, falling back to the default for
+// names the server cannot load
+func (s UserSettings) Location() *time.Location {
+	if loc, err := time.LoadLocation(s.TimeZone); err == nil && s.TimeZone != "" {
+		return loc
+	}
+	if loc, err := time.LoadLocation(DefaultTimeZone); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// Now is the current time on the user's wall clock
+func (s UserSettings) Now() time.Time {
+	return time.Now().In(s.Location())
+}
@@ -6,12 +6,16 @@ import (
 	"github.com/google/uuid"
 )
 
+// UserTransaction points at the legacy per-user JSON file.
+// Rows are kept only so database.Migrate can import them into Transaction.
 type UserTransaction struct {
-	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
-	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex" json:"user_id"`
-	FilePath  string    `gorm:"type:text" json:"file_path"` // JSON 檔案路徑
-	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	ID       uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex" json:"user_id"`
+	FilePath string    `gorm:"type:text" json:"file_path"` // JSON 檔案路徑
+	// MigratedAt is set once the file has been copied, so it is never imported again
+	MigratedAt *time.Time `json:"migrated_at"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
 
 	// Relationship
 	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
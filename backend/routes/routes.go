@@ -20,6 +20,35 @@ func SetupRoutes(r *gin.Engine) {
 		{
 			protected.GET("/transactions", handlers.GetTransactions)
 			protected.POST("/transactions", handlers.SaveTransactions)
+			protected.POST("/transactions/item", handlers.CreateTransaction)
+			protected.GET("/transactions/:id", handlers.GetTransaction)
+			protected.PATCH("/transactions/:id", handlers.UpdateTransaction)
+			protected.DELETE("/transactions/:id", handlers.DeleteTransaction)
+			protected.POST("/transactions/import", handlers.ImportTransactions)
+			protected.POST("/transactions/import/preview", handlers.PreviewImport)
+			protected.POST("/transactions/import/commit", handlers.CommitImport)
+			protected.GET("/import-profiles", handlers.GetImportProfiles)
+			protected.POST("/import-profiles", handlers.CreateImportProfile)
+			protected.PUT("/import-profiles/:id", handlers.UpdateImportProfile)
+			protected.DELETE("/import-profiles/:id", handlers.DeleteImportProfile)
+			protected.GET("/views", handlers.GetViews)
+			protected.POST("/views", handlers.CreateView)
+			protected.GET("/views/:id", handlers.GetView)
+			protected.PUT("/views/:id", handlers.UpdateView)
+			protected.DELETE("/views/:id", handlers.DeleteView)
+			protected.GET("/views/:id/transactions", handlers.GetViewTransactions)
+			protected.GET("/duplicates", handlers.GetDuplicates)
+			protected.POST("/duplicates/:id/confirm", handlers.ConfirmDuplicate)
+			protected.POST("/duplicates/:id/dismiss", handlers.DismissDuplicate)
+			protected.GET("/settings", handlers.GetSettings)
+			protected.PUT("/settings", handlers.UpdateSettings)
+			protected.GET("/budgets", handlers.GetBudgets)
+			protected.POST("/budgets", handlers.CreateBudget)
+			protected.GET("/budgets/status", handlers.GetBudgetStatus)
+			protected.PUT("/budgets/:id", handlers.UpdateBudget)
+			protected.DELETE("/budgets/:id", handlers.DeleteBudget)
+			protected.GET("/reports/summary", handlers.GetSummary)
+			protected.GET("/reports/compare", handlers.CompareReports)
 			protected.POST("/line/bind", handlers.BindLineAccount)
 		}
 
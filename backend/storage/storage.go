@@ -3,7 +3,6 @@ package storage
 import (
 	"encoding/json"
 	"os"
-	"path/filepath"
 )
 
 // GetUploadDir returns the configured upload directory
@@ -15,34 +14,7 @@ func GetUploadDir() string {
 	return dir
 }
 
-// GetTransactionFilePath returns the path for user's transaction file
-func GetTransactionFilePath(userID string) string {
-	return filepath.Join(GetUploadDir(), "transactions", userID, "transactions.json")
-}
-
-// SaveTransactionFile saves transactions to a JSON file
-func SaveTransactionFile(userID string, transactions []map[string]interface{}) (string, error) {
-	filePath := GetTransactionFilePath(userID)
-
-	// 建立目錄
-	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
-		return "", err
-	}
-
-	// 寫入 JSON
-	data, err := json.MarshalIndent(transactions, "", "  ")
-	if err != nil {
-		return "", err
-	}
-
-	if err := os.WriteFile(filePath, data, 0644); err != nil {
-		return "", err
-	}
-
-	return filePath, nil
-}
-
-// ReadTransactionFile reads transactions from a JSON file
+// ReadTransactionFile reads transactions from a legacy JSON file
 func ReadTransactionFile(filePath string) ([]map[string]interface{}, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StoredDateLayout is the canonical form of Transaction.Date.
+// It matches the YYYYMMDD strings the frontend slices by month.
+const StoredDateLayout = "20060102"
+
+// rocYearOffset converts 民國 years to Gregorian years
+const rocYearOffset = 1911
+
+var (
+	// ErrAmbiguousDate is returned for dates such as 01/02/2024 that read
+	// differently as day/month and month/day
+	ErrAmbiguousDate = errors.New("ambiguous date")
+	// ErrInvalidDate is returned for text that is not a recognised date
+	ErrInvalidDate = errors.New("unrecognized date")
+)
+
+var (
+	compactDatePattern = regexp.MustCompile(`^(\d{7,8})$`)
+	splitDatePattern   = regexp.MustCompile(`^(\d{1,4})[-/.](\d{1,2})[-/.](\d{1,4})$`)
+)
+
+// timestampLayouts lists the formats seen in the 上次更新 column
+var timestampLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006/01/02 15:04:05",
+	"2006/1/2 15:04:05",
+	"2006/01/02 15:04",
+	"2006/1/2 15:04",
+	"20060102150405",
+	"2006-01-02",
+	"2006/01/02",
+}
+
+// ParseTimestamp parses a last-updated style timestamp
+func ParseTimestamp(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized timestamp: %q", s)
+}
+
+// dateTokens converts user-facing date format tokens to Go layout elements.
+// Longer tokens come first so MM is not read as two M.
+var dateTokens = []struct{ token, layout string }{
+	{"YYYY", "2006"},
+	{"MM", "01"},
+	{"DD", "02"},
+	{"M", "1"},
+	{"D", "2"},
+}
+
+// DateLayout turns a format such as "YYYY/MM/DD" into a Go time layout
+func DateLayout(format string) (string, error) {
+	var b strings.Builder
+	found := map[byte]bool{}
+	for i := 0; i < len(format); {
+		matched := false
+		for _, t := range dateTokens {
+			if strings.HasPrefix(format[i:], t.token) {
+				b.WriteString(t.layout)
+				found[t.token[0]] = true
+				i += len(t.token)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			if c := format[i]; c >= '0' && c <= '9' {
+				return "", fmt.Errorf("date format %q contains a digit", format)
+			}
+			b.WriteByte(format[i])
+			i++
+		}
+	}
+	if !found['Y'] || !found['M'] || !found['D'] {
+		return "", fmt.Errorf("date format %q must contain YYYY, MM and DD", format)
+	}
+	return b.String(), nil
+}
+
+// NormalizeDate recognises ISO (2023-12-01), slash or dot (2023/12/1),
+// compact (20231201), Chinese (2023年12月1日) and ROC (112/12/01, 1121201,
+// 民國112年12月1日) dates, ignoring any time of day after the date.
+// Two-digit years and day/month orders that cannot be told apart are
+// rejected with ErrAmbiguousDate.
+func NormalizeDate(value string) (time.Time, error) {
+	s := strings.TrimSpace(HalfWidth(value))
+	if i := strings.IndexAny(s, " T"); i > 0 {
+		s = s[:i]
+	}
+
+	roc := false
+	if strings.HasPrefix(s, "民國") {
+		roc = true
+		s = strings.TrimSpace(strings.TrimPrefix(s, "民國"))
+	}
+	if strings.Contains(s, "年") {
+		s = strings.NewReplacer("年", "/", "月", "/", "日", "").Replace(s)
+	}
+
+	if m := compactDatePattern.FindStringSubmatch(s); m != nil {
+		digits := m[1]
+		if len(digits) == 7 {
+			// ROC compact: YYYMMDD
+			return civilDate(atoi(digits[:3])+rocYearOffset, atoi(digits[3:5]), atoi(digits[5:]), value)
+		}
+		return civilDate(atoi(digits[:4]), atoi(digits[4:6]), atoi(digits[6:]), value)
+	}
+
+	m := splitDatePattern.FindStringSubmatch(s)
+	if m == nil {
+		return time.Time{}, fmt.Errorf("%w: %q", ErrInvalidDate, value)
+	}
+	a, b, c := m[1], m[2], m[3]
+
+	switch {
+	case len(a) == 4 && len(c) <= 2:
+		return civilDate(atoi(a), atoi(b), atoi(c), value)
+	case (len(a) == 3 || roc) && len(c) <= 2:
+		return civilDate(atoi(a)+rocYearOffset, atoi(b), atoi(c), value)
+	case len(a) <= 2 && len(c) == 4:
+		first, second := atoi(a), atoi(b)
+		switch {
+		case first > 12 || first == second:
+			return civilDate(atoi(c), second, first, value) // DD/MM/YYYY
+		case second > 12:
+			return civilDate(atoi(c), first, second, value) // MM/DD/YYYY
+		}
+		return time.Time{}, fmt.Errorf("%w: %q could be day/month or month/day", ErrAmbiguousDate, value)
+	case len(a) <= 2 && len(c) <= 2:
+		return time.Time{}, fmt.Errorf("%w: %q has a two-digit year", ErrAmbiguousDate, value)
+	}
+	return time.Time{}, fmt.Errorf("%w: %q", ErrInvalidDate, value)
+}
+
+// civilDate builds a date, rejecting overflow such as February 30
+func civilDate(year, month, day int, value string) (time.Time, error) {
+	t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if month < 1 || month > 12 || t.Day() != day || t.Month() != time.Month(month) {
+		return time.Time{}, fmt.Errorf("%w: %q is not a calendar date", ErrInvalidDate, value)
+	}
+	return t, nil
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
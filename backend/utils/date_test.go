@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeDate(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"2023-12-01", "20231201"},
+		{"2023-12-01T12:00:00Z", "20231201"},
+		{"2023/12/1", "20231201"},
+		{"2023.12.01", "20231201"},
+		{"20231201", "20231201"},
+		{"2023年12月1日", "20231201"},
+		{"112/12/01", "20231201"},
+		{"112-12-1", "20231201"},
+		{"1121201", "20231201"},
+		{"民國112年12月1日", "20231201"},
+		{"民國99/1/5", "20100105"},
+		{"２０２３／１２／０１", "20231201"},
+		{"25/12/2023", "20231225"},
+		{"12/25/2023", "20231225"},
+		{"05/05/2023", "20230505"},
+	}
+	for _, tc := range cases {
+		got, err := NormalizeDate(tc.in)
+		if err != nil {
+			t.Errorf("NormalizeDate(%q) returned error: %v", tc.in, err)
+			continue
+		}
+		if s := got.Format(StoredDateLayout); s != tc.want {
+			t.Errorf("NormalizeDate(%q) = %s, want %s", tc.in, s, tc.want)
+		}
+	}
+}
+
+func TestNormalizeDateRejects(t *testing.T) {
+	cases := []struct {
+		in        string
+		ambiguous bool
+	}{
+		{"01/02/2024", true},
+		{"23/12/01", true},
+		{"2023-02-30", false},
+		{"2023-13-01", false},
+		{"yesterday", false},
+		{"", false},
+	}
+	for _, tc := range cases {
+		_, err := NormalizeDate(tc.in)
+		if err == nil {
+			t.Errorf("NormalizeDate(%q) should fail", tc.in)
+			continue
+		}
+		if errors.Is(err, ErrAmbiguousDate) != tc.ambiguous {
+			t.Errorf("NormalizeDate(%q) ambiguous = %v, want %v (%v)", tc.in, !tc.ambiguous, tc.ambiguous, err)
+		}
+	}
+}
@@ -20,7 +20,9 @@ func LogRequest(prefix string, content []byte) {
 	}
 	defer f.Close()
 
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
+	// Logs are read by operators, not users: stamp them in server time
+	// with the offset so entries are unambiguous
+	timestamp := time.Now().Format("2006-01-02 15:04:05 -0700")
 	entry := fmt.Sprintf("[%s] %s:\n%s\n\n------------------------------------------------\n\n", timestamp, prefix, string(content))
 
 	if _, err := f.WriteString(entry); err != nil {
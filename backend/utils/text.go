@@ -0,0 +1,17 @@
+package utils
+
+import "strings"
+
+// HalfWidth folds full-width ASCII variants (０-９, Ａ-Ｚ, ，, － …) and the
+// ideographic space into their ASCII forms, as typed on CJK keyboards
+func HalfWidth(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= '！' && r <= '～':
+			return r - '！' + '!'
+		case r == '　':
+			return ' '
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,23 @@
+-- One row per ledger entry, replacing the per-user JSON files
+CREATE TABLE IF NOT EXISTS transactions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    date VARCHAR(32) NOT NULL DEFAULT '',
+    category TEXT NOT NULL DEFAULT '',
+    main_category TEXT NOT NULL DEFAULT '',
+    amount DOUBLE PRECISION NOT NULL DEFAULT 0,
+    currency VARCHAR(8) NOT NULL DEFAULT '',
+    member TEXT NOT NULL DEFAULT '',
+    account TEXT NOT NULL DEFAULT '',
+    tags TEXT NOT NULL DEFAULT '',
+    note TEXT NOT NULL DEFAULT '',
+    type VARCHAR(8) NOT NULL DEFAULT '',
+    last_updated TEXT NOT NULL DEFAULT '',
+    source_uuid TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_transactions_user_date ON transactions(user_id, date);
+CREATE INDEX idx_transactions_user_category ON transactions(user_id, category);
+CREATE INDEX idx_transactions_user_source ON transactions(user_id, source_uuid);
@@ -0,0 +1 @@
+ALTER TABLE user_transactions DROP COLUMN IF EXISTS migrated_at;
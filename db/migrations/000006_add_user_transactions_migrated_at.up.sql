@@ -0,0 +1,2 @@
+-- Set once a legacy JSON file has been copied into transactions, so it is never imported again
+ALTER TABLE user_transactions ADD COLUMN migrated_at TIMESTAMP WITH TIME ZONE;
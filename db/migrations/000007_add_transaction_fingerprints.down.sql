@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_transactions_duplicate_of_id;
+DROP INDEX IF EXISTS idx_transactions_user_fingerprint;
+ALTER TABLE transactions DROP COLUMN IF EXISTS duplicate_of_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS fingerprint;
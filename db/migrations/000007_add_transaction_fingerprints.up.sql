@@ -0,0 +1,7 @@
+-- Fingerprints flag probable duplicates among rows without a UUID; held rows
+-- point at the row they duplicate until the user reviews them
+ALTER TABLE transactions ADD COLUMN fingerprint CHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE transactions ADD COLUMN duplicate_of_id UUID;
+
+CREATE INDEX idx_transactions_user_fingerprint ON transactions(user_id, fingerprint);
+CREATE INDEX idx_transactions_duplicate_of_id ON transactions(duplicate_of_id);
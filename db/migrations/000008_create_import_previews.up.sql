@@ -0,0 +1,12 @@
+-- Change sets computed by an import preview, applied by the matching commit
+CREATE TABLE IF NOT EXISTS import_previews (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    plan JSONB NOT NULL,
+    ledger_version CHAR(64) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_import_previews_user_id ON import_previews(user_id);
+CREATE INDEX idx_import_previews_expires_at ON import_previews(expires_at);
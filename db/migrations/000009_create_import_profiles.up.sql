@@ -0,0 +1,13 @@
+-- User-defined CSV column mappings
+CREATE TABLE IF NOT EXISTS import_profiles (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    columns JSONB NOT NULL,
+    decimal_separator VARCHAR(1) NOT NULL DEFAULT '.',
+    sign_convention TEXT NOT NULL DEFAULT 'type_column',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_import_profiles_user_name ON import_profiles(user_id, name);
@@ -0,0 +1,2 @@
+ALTER TABLE import_profiles DROP COLUMN IF EXISTS date_format;
+ALTER TABLE transactions DROP COLUMN IF EXISTS date_raw;
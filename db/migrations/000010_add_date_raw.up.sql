@@ -0,0 +1,4 @@
+-- Dates are stored as YYYYMMDD; date_raw keeps the text as it was imported.
+-- Existing rows are normalized by the backend on its next start.
+ALTER TABLE transactions ADD COLUMN date_raw VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE import_profiles ADD COLUMN date_format TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,9 @@
+ALTER TABLE transactions ADD COLUMN amount DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+UPDATE transactions SET amount = amount_minor::double precision / CASE
+    WHEN currency IN ('TWD', 'JPY', 'KRW', 'VND', 'CLP', 'ISK') THEN 1
+    WHEN currency IN ('BHD', 'JOD', 'KWD', 'OMR', 'TND') THEN 1000
+    ELSE 100
+END;
+
+ALTER TABLE transactions DROP COLUMN amount_minor;
@@ -0,0 +1,33 @@
+-- Amounts become integers in each currency's minor unit (models.CurrencyExponents),
+-- rounded half away from zero. Currencies are normalized the way imports do.
+-- Fingerprints include the amount, so they are cleared for the backend to recompute.
+ALTER TABLE transactions ADD COLUMN amount_minor BIGINT NOT NULL DEFAULT 0;
+
+UPDATE transactions SET currency = CASE upper(btrim(currency))
+    WHEN '' THEN 'TWD'
+    WHEN 'NTD' THEN 'TWD'
+    WHEN 'NT$' THEN 'TWD'
+    WHEN '新台幣' THEN 'TWD'
+    WHEN '新臺幣' THEN 'TWD'
+    WHEN '台幣' THEN 'TWD'
+    WHEN '臺幣' THEN 'TWD'
+    WHEN 'RMB' THEN 'CNY'
+    WHEN '人民幣' THEN 'CNY'
+    WHEN '美元' THEN 'USD'
+    WHEN 'US$' THEN 'USD'
+    WHEN '日圓' THEN 'JPY'
+    WHEN '日元' THEN 'JPY'
+    WHEN '歐元' THEN 'EUR'
+    WHEN 'EURO' THEN 'EUR'
+    ELSE upper(btrim(currency))
+END;
+
+UPDATE transactions SET
+    amount_minor = round(amount::numeric * CASE
+        WHEN currency IN ('TWD', 'JPY', 'KRW', 'VND', 'CLP', 'ISK') THEN 1
+        WHEN currency IN ('BHD', 'JOD', 'KWD', 'OMR', 'TND') THEN 1000
+        ELSE 100
+    END),
+    fingerprint = '';
+
+ALTER TABLE transactions DROP COLUMN amount;
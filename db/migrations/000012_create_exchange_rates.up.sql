@@ -0,0 +1,18 @@
+-- Daily reference rates: 1 base = rate currency
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    id BIGSERIAL PRIMARY KEY,
+    date VARCHAR(8) NOT NULL,
+    base VARCHAR(8) NOT NULL,
+    currency VARCHAR(8) NOT NULL,
+    rate NUMERIC(24,10) NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_exchange_rates_pair_date ON exchange_rates(base, currency, date);
+
+-- Per-user preferences; users without a row get the defaults
+CREATE TABLE IF NOT EXISTS user_settings (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    base_currency VARCHAR(8) NOT NULL DEFAULT 'TWD',
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
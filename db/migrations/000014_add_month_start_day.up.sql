@@ -0,0 +1 @@
+ALTER TABLE user_settings ADD COLUMN month_start_day INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,2 @@
+ALTER TABLE user_settings ADD COLUMN time_zone VARCHAR(64) NOT NULL DEFAULT 'Asia/Taipei';
+ALTER TABLE user_settings ADD COLUMN locale VARCHAR(35) NOT NULL DEFAULT 'zh-TW';
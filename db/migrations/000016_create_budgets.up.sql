@@ -0,0 +1,15 @@
+-- Spending limits per category or main category and period
+CREATE TABLE IF NOT EXISTS budgets (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    scope VARCHAR(16) NOT NULL DEFAULT 'category',
+    category TEXT NOT NULL,
+    period VARCHAR(8) NOT NULL DEFAULT 'month',
+    amount_minor BIGINT NOT NULL,
+    currency VARCHAR(8) NOT NULL DEFAULT 'TWD',
+    rollover VARCHAR(8) NOT NULL DEFAULT 'none',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_budgets_user_target ON budgets(user_id, scope, category, period);
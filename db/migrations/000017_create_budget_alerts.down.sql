@@ -0,0 +1,3 @@
+ALTER TABLE user_settings DROP COLUMN IF EXISTS budget_warn_percent;
+ALTER TABLE user_settings DROP COLUMN IF EXISTS budget_alerts;
+DROP TABLE IF EXISTS budget_alerts;
@@ -0,0 +1,13 @@
+-- Budget thresholds already announced, at most once per budget period
+CREATE TABLE IF NOT EXISTS budget_alerts (
+    id BIGSERIAL PRIMARY KEY,
+    budget_id UUID NOT NULL REFERENCES budgets(id) ON DELETE CASCADE,
+    period_from VARCHAR(8) NOT NULL,
+    threshold INTEGER NOT NULL,
+    sent_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_budget_alerts_once ON budget_alerts(budget_id, period_from, threshold);
+
+ALTER TABLE user_settings ADD COLUMN budget_alerts VARCHAR(8) NOT NULL DEFAULT 'all';
+ALTER TABLE user_settings ADD COLUMN budget_warn_percent INTEGER NOT NULL DEFAULT 80;
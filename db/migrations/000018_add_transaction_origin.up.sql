@@ -0,0 +1,2 @@
+-- Where a hand-entered row came from: web or line; empty for imports
+ALTER TABLE transactions ADD COLUMN origin VARCHAR(8) NOT NULL DEFAULT '';
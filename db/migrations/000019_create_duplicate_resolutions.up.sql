@@ -0,0 +1,8 @@
+-- The user's review of a probable duplicate, so re-imports of the same row are not held again
+CREATE TABLE IF NOT EXISTS duplicate_resolutions (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    fingerprint CHAR(64) NOT NULL,
+    resolution VARCHAR(16) NOT NULL,
+    resolved_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, fingerprint)
+);
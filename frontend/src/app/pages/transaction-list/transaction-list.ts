@@ -40,6 +40,13 @@ export class TransactionList {
   private transactionService = inject(TransactionService);
 
   transactions = this.transactionService.transactions;
+  total = this.transactionService.total;
+  hasMore = this.transactionService.hasMore;
+  isLoading = this.transactionService.isLoading;
+
+  loadMore() {
+    this.transactionService.loadMore().subscribe();
+  }
 
   // 搜尋關鍵字
   keyword = signal('');
@@ -1,5 +1,5 @@
-import { Injectable, inject, signal, effect } from '@angular/core';
-import { HttpClient, HttpHeaders } from '@angular/common/http';
+import { Injectable, inject, signal, computed, effect } from '@angular/core';
+import { HttpClient, HttpHeaders, HttpParams } from '@angular/common/http';
 import { Transaction } from '../models/transaction.model';
 import { AuthService } from './auth.service';
 import { Observable, tap, catchError, of } from 'rxjs';
@@ -7,8 +7,13 @@ import { environment } from '../../environments/environment';
 
 interface TransactionsResponse {
   transactions: Transaction[];
+  total: number;
+  next_cursor: string; // 最後一頁時為空字串
 }
 
+// 每次向後端載入的筆數
+const PAGE_SIZE = 100;
+
 @Injectable({
   providedIn: 'root',
 })
@@ -21,6 +26,10 @@ export class TransactionService {
   readonly transactions = signal<Transaction[]>([]);
   readonly isLoading = signal<boolean>(false);
   readonly isLoaded = signal<boolean>(false);
+  // 符合條件的總筆數，以及下一頁的游標
+  readonly total = signal<number>(0);
+  private readonly nextCursor = signal<string>('');
+  readonly hasMore = computed(() => this.nextCursor() !== '');
 
   constructor() {
     // 監聽登入狀態變化，自動載入或清除交易資料
@@ -41,17 +50,38 @@ export class TransactionService {
     });
   }
 
-  // 從後端載入交易資料
+  // 從後端載入第一頁交易資料
   loadTransactions(): Observable<TransactionsResponse> {
-    this.isLoading.set(true);
     this.isLoaded.set(false);
+    return this.fetchPage('', (rows) => this.transactions.set(rows));
+  }
+
+  // 載入下一頁並接在目前的列表後面
+  loadMore(): Observable<TransactionsResponse> {
+    return this.fetchPage(this.nextCursor(), (rows) =>
+      this.transactions.update((current) => [...current, ...rows]),
+    );
+  }
+
+  private fetchPage(
+    cursor: string,
+    apply: (rows: Transaction[]) => void,
+  ): Observable<TransactionsResponse> {
+    this.isLoading.set(true);
+    let params = new HttpParams().set('limit', PAGE_SIZE).set('sort', '-date');
+    if (cursor) {
+      params = params.set('cursor', cursor);
+    }
     return this.http
       .get<TransactionsResponse>(this.apiUrl, {
         headers: this.getAuthHeaders(),
+        params,
       })
       .pipe(
         tap((response) => {
-          this.transactions.set(response.transactions || []);
+          apply(response.transactions || []);
+          this.total.set(response.total ?? 0);
+          this.nextCursor.set(response.next_cursor ?? '');
           this.isLoading.set(false);
           this.isLoaded.set(true);
         }),
@@ -59,7 +89,7 @@ export class TransactionService {
           console.error('Failed to load transactions', error);
           this.isLoading.set(false);
           this.isLoaded.set(true);
-          return of({ transactions: [] });
+          return of({ transactions: [], total: this.total(), next_cursor: this.nextCursor() });
         }),
       );
   }
@@ -78,6 +108,8 @@ export class TransactionService {
       .pipe(
         tap(() => {
           this.transactions.set(data);
+          this.total.set(data.length);
+          this.nextCursor.set('');
           this.isLoading.set(false);
         }),
         catchError((error) => {
@@ -98,6 +130,8 @@ export class TransactionService {
 
   clearTransactions() {
     this.transactions.set([]);
+    this.total.set(0);
+    this.nextCursor.set('');
     this.isLoaded.set(false);
   }
 }